@@ -1,105 +1,260 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
 	"github.com/nathfavour/ideasbglobot/internal"
 )
 
-var ollamaModel string = "llama3" // default, can be changed by user
+var (
+	aiJSON      bool
+	aiModelName string
+	aiForce     bool
+)
 
 var AiCmd = &cobra.Command{
 	Use:   "ai",
 	Short: "Interact with Ollama AI models",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Current Ollama model: %s\n", ollamaModel)
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		fmt.Printf("Current Ollama model: %s\n", cfg.AIModel())
 	},
 }
 
-var ollamaModelSetCmd = &cobra.Command{
-	Use:   "ollama model set",
-	Short: "Scan Ollama installation, list models, and select model for AI functionalities",
+var aiModelCmd = &cobra.Command{
+	Use:   "model",
+	Short: "List, select, inspect, pull and remove Ollama models",
+}
+
+var aiModelListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed Ollama models (* marks the default)",
 	Run: func(cmd *cobra.Command, args []string) {
-		models, err := listOllamaModels()
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		models, err := internal.ListOllamaModels()
 		if err != nil {
 			fmt.Printf("Error listing Ollama models: %v\n", err)
 			return
 		}
+		if aiJSON {
+			printJSON(models)
+			return
+		}
 		if len(models) == 0 {
 			fmt.Println("No Ollama models found.")
 			return
 		}
-		fmt.Println("Available Ollama models:")
-		for i, m := range models {
-			fmt.Printf("  [%d] %s\n", i+1, m)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "\tNAME\tSIZE\tFAMILY\tPARAMS\tQUANT\tMODIFIED")
+		for _, m := range models {
+			mark := ""
+			if isModel(m.Name, cfg.AIModel()) {
+				mark = "*"
+			}
+			modified := "-"
+			if !m.ModifiedAt.IsZero() {
+				modified = m.ModifiedAt.Local().Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", mark, m.Name, internal.FormatBytes(m.Size),
+				m.Details.Family, m.Details.ParameterSize, m.Details.QuantizationLevel, modified)
 		}
-		fmt.Print("Select model number to use: ")
-		reader := bufio.NewReader(os.Stdin)
-		choiceStr, _ := reader.ReadString('\n')
-		choiceStr = strings.TrimSpace(choiceStr)
-		var idx int
-		fmt.Sscanf(choiceStr, "%d", &idx)
-		if idx < 1 || idx > len(models) {
-			fmt.Println("Invalid selection.")
+		tw.Flush()
+	},
+}
+
+var aiModelSetCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Set the default model in configs.json",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := modelArg(args)
+		if name == "" {
+			fmt.Println("Error: pass a model name as an argument or with --model")
 			return
 		}
-		ollamaModel = models[idx-1]
-		fmt.Printf("Ollama model set to: %s\n", ollamaModel)
-
-		// Persist to config
+		if !aiForce {
+			if _, err := internal.FindOllamaModel(name); err != nil {
+				fmt.Printf("Error: %v (use --force to set it anyway, or pull it first)\n", err)
+				return
+			}
+		}
 		cfg, err := internal.EnsureConfigFile()
 		if err != nil {
 			fmt.Printf("Error loading config: %v\n", err)
 			return
 		}
-		cfg.DefaultAIModel = ollamaModel
-		fmt.Printf("DEBUG: cfg.DefaultAIModel before save: %s\n", cfg.DefaultAIModel)
-		configPath, err := internal.GetConfigPath()
+		previous := cfg.AIModel()
+		cfg.DefaultAIModel = name
+		if err := saveCurrentConfig(cfg); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+		if aiJSON {
+			printJSON(map[string]string{"model": name, "previous": previous})
+			return
+		}
+		fmt.Printf("Default AI model set to %s (was %s)\n", name, previous)
+	},
+}
+
+var aiModelShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show details of a model (default: the configured model)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := modelArg(args)
+		if name == "" {
+			cfg, err := internal.EnsureConfigFile()
+			if err != nil {
+				fmt.Printf("Error loading config: %v\n", err)
+				return
+			}
+			name = cfg.AIModel()
+		}
+		info, err := internal.OllamaShow(name)
 		if err != nil {
-			fmt.Printf("Error getting config path: %v\n", err)
+			fmt.Printf("Error showing model %s: %v\n", name, err)
 			return
 		}
-		if err := internal.SaveConfig(configPath, cfg); err != nil {
-			fmt.Printf("Error saving config: %v\n", err)
+		installed, _ := internal.FindOllamaModel(name)
+		if aiJSON {
+			out := map[string]interface{}{"name": name, "details": info.Details, "context_length": info.ContextLength(), "parameters": info.Parameters}
+			if installed != nil {
+				out["size"] = installed.Size
+				out["modified_at"] = installed.ModifiedAt
+			}
+			printJSON(out)
 			return
 		}
-		fmt.Println("Model selection persisted to config.")
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Name:\t%s\n", name)
+		if installed != nil {
+			fmt.Fprintf(tw, "Size:\t%s\n", internal.FormatBytes(installed.Size))
+			fmt.Fprintf(tw, "Modified:\t%s\n", installed.ModifiedAt.Local().Format("2006-01-02 15:04"))
+		}
+		fmt.Fprintf(tw, "Family:\t%s\n", info.Details.Family)
+		fmt.Fprintf(tw, "Parameters:\t%s\n", info.Details.ParameterSize)
+		fmt.Fprintf(tw, "Quantization:\t%s\n", info.Details.QuantizationLevel)
+		fmt.Fprintf(tw, "Format:\t%s\n", info.Details.Format)
+		if n := info.ContextLength(); n > 0 {
+			fmt.Fprintf(tw, "Context length:\t%d\n", n)
+		}
+		tw.Flush()
+		if p := strings.TrimSpace(info.Parameters); p != "" {
+			fmt.Printf("\nParameters:\n%s\n", p)
+		}
 	},
 }
 
-func listOllamaModels() ([]string, error) {
-	resp, err := http.Get("http://localhost:11434/api/tags")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	var result struct {
-		Models []struct {
-			Name string `json:"name"`
-		} `json:"models"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-	var models []string
-	for _, m := range result.Models {
-		models = append(models, m.Name)
+var aiModelPullCmd = &cobra.Command{
+	Use:   "pull [name]",
+	Short: "Download a model from the Ollama library",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := modelArg(args)
+		if name == "" {
+			fmt.Println("Error: pass a model name as an argument or with --model")
+			return
+		}
+		enc := json.NewEncoder(os.Stdout)
+		last := ""
+		err := internal.OllamaPull(context.Background(), name, func(p internal.PullProgress) {
+			if aiJSON {
+				enc.Encode(p)
+				return
+			}
+			line := p.Status
+			if pct := p.Percent(); pct >= 0 {
+				line = fmt.Sprintf("%s %3d%% (%s / %s)", p.Status, pct, internal.FormatBytes(p.Completed), internal.FormatBytes(p.Total))
+			}
+			if line != last {
+				fmt.Printf("\r\033[K%s", line)
+				last = line
+			}
+		})
+		if !aiJSON {
+			fmt.Println()
+		}
+		if err != nil {
+			fmt.Printf("Error pulling %s: %v\n", name, err)
+			return
+		}
+		if !aiJSON {
+			fmt.Printf("Pulled %s\n", name)
+		}
+	},
+}
+
+var aiModelRmCmd = &cobra.Command{
+	Use:   "rm [name]",
+	Short: "Remove an installed model",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := modelArg(args)
+		if name == "" {
+			fmt.Println("Error: pass a model name as an argument or with --model")
+			return
+		}
+		if err := internal.OllamaDelete(name); err != nil {
+			fmt.Printf("Error removing %s: %v\n", name, err)
+			return
+		}
+		cfg, err := internal.EnsureConfigFile()
+		isDefault := err == nil && isModel(name, cfg.AIModel())
+		if aiJSON {
+			printJSON(map[string]interface{}{"removed": name, "was_default": isDefault})
+			return
+		}
+		fmt.Printf("Removed %s\n", name)
+		if isDefault {
+			fmt.Println("Warning: this was the default model; choose another with `ideasbglobot ai model set <name>`.")
+		}
+	},
+}
+
+// modelArg returns the model named by the positional argument or --model.
+func modelArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
 	}
-	return models, nil
+	return aiModelName
 }
 
-// Exported function to get the current ollama model
-func OllamaModel() string {
-	return ollamaModel
+// isModel reports whether an installed model name refers to want, treating a
+// name without a tag as ":latest".
+func isModel(name, want string) bool {
+	return name == want || name == want+":latest"
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
 }
 
 func init() {
-	AiCmd.AddCommand(ollamaModelSetCmd)
+	for _, c := range []*cobra.Command{aiModelListCmd, aiModelSetCmd, aiModelShowCmd, aiModelPullCmd, aiModelRmCmd} {
+		c.Flags().BoolVar(&aiJSON, "json", false, "output JSON")
+	}
+	for _, c := range []*cobra.Command{aiModelSetCmd, aiModelShowCmd, aiModelPullCmd, aiModelRmCmd} {
+		c.Flags().StringVar(&aiModelName, "model", "", "model name (instead of the positional argument)")
+	}
+	aiModelSetCmd.Flags().BoolVar(&aiForce, "force", false, "set the model even if it is not installed")
+	aiModelCmd.AddCommand(aiModelListCmd, aiModelSetCmd, aiModelShowCmd, aiModelPullCmd, aiModelRmCmd)
+	AiCmd.AddCommand(aiModelCmd)
 }
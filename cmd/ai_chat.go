@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nathfavour/ideasbglobot/internal"
+)
+
+var (
+	aiSystem  string
+	aiSession string
+	aiSave    bool
+)
+
+const aiChatHelp = `Commands:
+  /system [text]   show or set the system prompt
+  /model [name]    show or switch the model
+  /save [name]     save this session to the database (later turns are saved too)
+  /history         print the conversation so far
+  /reset           clear the conversation
+  /exit            quit`
+
+var aiAskCmd = &cobra.Command{
+	Use:   "ask [prompt]",
+	Short: "Ask the configured model once; piped stdin is appended to the prompt",
+	Example: `  ideasbglobot ai ask "why does this test fail?" < test.log
+  git diff | ideasbglobot ai ask "review this diff"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		prompt := strings.Join(args, " ")
+		if stdinPiped() {
+			in, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Printf("Error reading stdin: %v\n", err)
+				return
+			}
+			if s := strings.TrimSpace(string(in)); s != "" {
+				prompt = strings.TrimSpace(prompt + "\n\n" + s)
+			}
+		}
+		if prompt == "" {
+			fmt.Println("Error: pass a prompt as arguments or on stdin")
+			return
+		}
+		model, err := chatModel()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		var messages []internal.ChatMessage
+		if aiSystem != "" {
+			messages = append(messages, internal.ChatMessage{Role: "system", Content: aiSystem})
+		}
+		messages = append(messages, internal.ChatMessage{Role: "user", Content: prompt})
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		_, err = internal.OllamaChatStream(ctx, internal.ChatRequest{Model: model, Messages: messages}, func(tok string) {
+			fmt.Print(tok)
+		})
+		fmt.Println()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+var aiChatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Chat with the configured model in an interactive session",
+	Run: func(cmd *cobra.Command, args []string) {
+		model, err := chatModel()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		session := &internal.ChatSession{Model: model, System: aiSystem}
+		stored := -1 // messages already saved; -1 while the session is not persisted
+		if aiSession != "" || aiSave {
+			if err := internal.EnsureDatabase(); err != nil {
+				fmt.Printf("Failed to initialize database: %v\n", err)
+				return
+			}
+		}
+		if aiSession != "" {
+			if session, err = internal.LoadChatSession(aiSession); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			stored = len(session.Messages)
+			fmt.Printf("Resumed session #%d %s (%d messages, model %s)\n", session.ID, session.Name, stored, session.Model)
+		} else if aiSave {
+			stored = 0
+		}
+		save := func() {
+			if stored < 0 {
+				return
+			}
+			if err := internal.SaveChatSession(session, stored); err != nil {
+				fmt.Printf("Error saving session: %v\n", err)
+				return
+			}
+			stored = len(session.Messages)
+		}
+
+		fmt.Printf("Chatting with %s. Type /help for commands, /exit to quit.\n", session.Model)
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for {
+			fmt.Print("> ")
+			if !scanner.Scan() {
+				fmt.Println()
+				return
+			}
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			if strings.HasPrefix(line, "/") {
+				name, arg, _ := strings.Cut(line, " ")
+				arg = strings.TrimSpace(arg)
+				switch name {
+				case "/exit", "/quit":
+					return
+				case "/help":
+					fmt.Println(aiChatHelp)
+				case "/system":
+					if arg == "" {
+						fmt.Printf("System prompt: %q\n", session.System)
+						continue
+					}
+					session.System = arg
+					fmt.Println("System prompt updated.")
+					save()
+				case "/model":
+					if arg == "" {
+						fmt.Printf("Model: %s\n", session.Model)
+						continue
+					}
+					session.Model = arg
+					fmt.Printf("Switched to %s.\n", arg)
+					save()
+				case "/save":
+					if stored < 0 {
+						if err := internal.EnsureDatabase(); err != nil {
+							fmt.Printf("Failed to initialize database: %v\n", err)
+							continue
+						}
+						stored = 0
+					}
+					if arg != "" {
+						session.Name = arg
+					}
+					save()
+					fmt.Printf("Saved as session #%d. Resume with `ideasbglobot ai chat --session %d`.\n", session.ID, session.ID)
+				case "/history":
+					for _, m := range session.Messages {
+						fmt.Printf("[%s] %s\n", m.Role, m.Content)
+					}
+				case "/reset":
+					session.Messages = nil
+					if stored >= 0 {
+						// Start a new stored session rather than rewriting the old one.
+						session.ID, session.Name, stored = 0, "", 0
+					}
+					fmt.Println("Conversation cleared.")
+				default:
+					fmt.Println(aiChatHelp)
+				}
+				continue
+			}
+
+			session.Messages = append(session.Messages, internal.ChatMessage{Role: "user", Content: line})
+			messages := session.Messages
+			if session.System != "" {
+				messages = append([]internal.ChatMessage{{Role: "system", Content: session.System}}, messages...)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			resp, err := internal.OllamaChatStream(ctx, internal.ChatRequest{Model: session.Model, Messages: messages}, func(tok string) {
+				fmt.Print(tok)
+			})
+			stop()
+			fmt.Println()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				session.Messages = session.Messages[:len(session.Messages)-1]
+				continue
+			}
+			session.Messages = append(session.Messages, resp.Message)
+			save()
+		}
+	},
+}
+
+var aiSessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List saved chat sessions",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		sessions, counts, err := internal.ListChatSessions()
+		if err != nil {
+			fmt.Printf("Error listing sessions: %v\n", err)
+			return
+		}
+		if aiJSON {
+			printJSON(sessions)
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tMODEL\tMESSAGES\tUPDATED")
+		for i, s := range sessions {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\n", s.ID, s.Name, s.Model, counts[i], s.Updated.Local().Format("2006-01-02 15:04"))
+		}
+		tw.Flush()
+	},
+}
+
+var aiSessionsRmCmd = &cobra.Command{
+	Use:   "rm <id|name>",
+	Short: "Delete a saved chat session",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		s, err := internal.LoadChatSession(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if err := internal.DeleteChatSession(s.ID); err != nil {
+			fmt.Printf("Error deleting session %d: %v\n", s.ID, err)
+			return
+		}
+		if aiJSON {
+			printJSON(map[string]interface{}{"removed": s.ID, "name": s.Name, "messages": len(s.Messages)})
+			return
+		}
+		fmt.Printf("Deleted session %d (%d messages)\n", s.ID, len(s.Messages))
+	},
+}
+
+// chatModel returns --model or the configured default model.
+func chatModel() (string, error) {
+	if aiModelName != "" {
+		return aiModelName, nil
+	}
+	cfg, err := internal.EnsureConfigFile()
+	if err != nil {
+		return "", err
+	}
+	return cfg.AIModel(), nil
+}
+
+// stdinPiped reports whether stdin is a pipe or file rather than a terminal.
+func stdinPiped() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+func init() {
+	for _, c := range []*cobra.Command{aiAskCmd, aiChatCmd} {
+		c.Flags().StringVar(&aiModelName, "model", "", "model to use (default: the configured model)")
+		c.Flags().StringVar(&aiSystem, "system", "", "system prompt")
+	}
+	aiChatCmd.Flags().StringVar(&aiSession, "session", "", "resume a saved session by ID or name")
+	aiChatCmd.Flags().BoolVar(&aiSave, "save", false, "save this session to the database")
+	aiSessionsCmd.PersistentFlags().BoolVar(&aiJSON, "json", false, "output JSON")
+	aiSessionsCmd.AddCommand(aiSessionsRmCmd)
+	AiCmd.AddCommand(aiAskCmd, aiChatCmd, aiSessionsCmd)
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nathfavour/ideasbglobot/internal"
+)
+
+var (
+	arCategory  string
+	arReplies   []string
+	arKeywords  []string
+	arPatterns  []string
+	arPriority  int
+	arChats     []int64
+	arMatch     string
+	arSelection string
+	arWeights   []int
+	arCooldown  int
+	arContext   string
+	arEnabled   bool
+	arJSON      bool
+	arReplace   bool
+	arChatID    int64
+)
+
+var AutoReplyCmd = &cobra.Command{
+	Use:   "autoreply",
+	Short: "Manage auto-reply rules stored in the database",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			os.Exit(1)
+		}
+		internal.EnsureAutoReplies()
+	},
+}
+
+var autoReplyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List auto-reply rules",
+	Run: func(cmd *cobra.Command, args []string) {
+		rules, err := internal.ListAutoReplies()
+		if err != nil {
+			fmt.Printf("Error listing auto-replies: %v\n", err)
+			return
+		}
+		if arJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(rules)
+			return
+		}
+		for _, r := range rules {
+			fmt.Println(internal.FormatAutoReply(r))
+		}
+	},
+}
+
+var autoReplyAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add an auto-reply rule",
+	Run: func(cmd *cobra.Command, args []string) {
+		ar := internal.AutoReply{}
+		applyAutoReplyFlags(cmd, &ar)
+		if len(ar.Variants()) == 0 {
+			fmt.Println("At least one --reply is required.")
+			return
+		}
+		if err := internal.SaveAutoReply(&ar); err != nil {
+			fmt.Printf("Error saving auto-reply: %v\n", err)
+			return
+		}
+		fmt.Printf("Added auto-reply #%d.\n", ar.ID)
+	},
+}
+
+var autoReplyEditCmd = &cobra.Command{
+	Use:   "edit <id>",
+	Short: "Change fields of an auto-reply rule; only the given flags are updated",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ar, err := findAutoReplyArg(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		applyAutoReplyFlags(cmd, ar)
+		if err := internal.SaveAutoReply(ar); err != nil {
+			fmt.Printf("Error saving auto-reply: %v\n", err)
+			return
+		}
+		fmt.Println(internal.FormatAutoReply(*ar))
+	},
+}
+
+var autoReplyRmCmd = &cobra.Command{
+	Use:   "rm <id>",
+	Short: "Delete an auto-reply rule",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Printf("Invalid id: %s\n", args[0])
+			return
+		}
+		if err := internal.DeleteAutoReply(id); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Deleted auto-reply #%d.\n", id)
+	},
+}
+
+var autoReplyTestCmd = &cobra.Command{
+	Use:   "test <text>",
+	Short: "Show which rule a message would trigger and a sample reply",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		text := strings.Join(args, " ")
+		rule, keyword := internal.MatchAutoReply(text, arChatID)
+		if rule == nil {
+			fmt.Println("No rule matches.")
+			return
+		}
+		fmt.Printf("Matched %q\n%s\n", keyword, internal.FormatAutoReply(*rule))
+		reply, _ := internal.RenderAutoReply(rule, arChatID, internal.AutoReplyData{
+			Username: "alice", FirstName: "Alice", ChatTitle: "test chat", ChatID: arChatID, Keyword: keyword,
+		})
+		fmt.Printf("\nSample reply: %s\n", reply)
+	},
+}
+
+var autoReplyImportCmd = &cobra.Command{
+	Use:   "import [auto.json]",
+	Short: "Import rules from auto.json (default: the one in the app directory)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := autoJSONArg(args)
+		n, err := internal.ImportAutoReplies(path, arReplace)
+		if err != nil {
+			fmt.Printf("Error importing %s: %v\n", path, err)
+			return
+		}
+		fmt.Printf("Imported %d rules from %s.\n", n, path)
+	},
+}
+
+var autoReplyExportCmd = &cobra.Command{
+	Use:   "export [auto.json]",
+	Short: "Export rules to auto.json (default: the one in the app directory)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := autoJSONArg(args)
+		n, err := internal.ExportAutoReplies(path)
+		if err != nil {
+			fmt.Printf("Error exporting to %s: %v\n", path, err)
+			return
+		}
+		fmt.Printf("Exported %d rules to %s.\n", n, path)
+	},
+}
+
+func autoJSONArg(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	return filepath.Join(internal.GetAppDir(), "auto.json")
+}
+
+func findAutoReplyArg(arg string) (*internal.AutoReply, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id: %s", arg)
+	}
+	return internal.FindAutoReply(id)
+}
+
+// applyAutoReplyFlags copies the flags the user actually set onto ar.
+func applyAutoReplyFlags(cmd *cobra.Command, ar *internal.AutoReply) {
+	f := cmd.Flags()
+	if f.Changed("category") {
+		ar.Category = arCategory
+	}
+	if f.Changed("reply") {
+		ar.Reply, ar.Replies = "", nil
+		if len(arReplies) > 0 {
+			ar.Reply, ar.Replies = arReplies[0], arReplies[1:]
+		}
+	}
+	if f.Changed("keyword") {
+		ar.Keywords = arKeywords
+	}
+	if f.Changed("pattern") {
+		ar.Patterns = arPatterns
+	}
+	if f.Changed("priority") {
+		ar.Priority = arPriority
+	}
+	if f.Changed("chat") {
+		ar.Chats = arChats
+	}
+	if f.Changed("match") {
+		ar.MatchMode = arMatch
+	}
+	if f.Changed("selection") {
+		ar.Selection = arSelection
+	}
+	if f.Changed("weight") {
+		ar.Weights = arWeights
+	}
+	if f.Changed("cooldown") {
+		ar.CooldownSeconds = arCooldown
+	}
+	if f.Changed("context") {
+		ar.Context = arContext
+	}
+	if f.Changed("enabled") {
+		enabled := arEnabled
+		ar.Enabled = &enabled
+	}
+}
+
+func addAutoReplyFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringVar(&arCategory, "category", "", "category / message type the rule belongs to")
+	f.StringArrayVar(&arReplies, "reply", nil, "reply text or template; repeat for variants")
+	f.StringSliceVar(&arKeywords, "keyword", nil, "keywords that trigger the rule (comma-separated or repeated)")
+	f.StringArrayVar(&arPatterns, "pattern", nil, "case-insensitive regular expression; repeat for more")
+	f.IntVar(&arPriority, "priority", 0, "higher priorities are matched first")
+	f.Int64SliceVar(&arChats, "chat", nil, "restrict the rule to these chat IDs")
+	f.StringVar(&arMatch, "match", "", "keyword match mode: word, substring or fuzzy")
+	f.StringVar(&arSelection, "selection", "", "reply selection: random, round_robin or weighted")
+	f.IntSliceVar(&arWeights, "weight", nil, "per-reply weights for weighted selection")
+	f.IntVar(&arCooldown, "cooldown", 0, "seconds before the rule may reply again in the same chat")
+	f.StringVar(&arContext, "context", "", "free-form description of when the rule applies")
+	f.BoolVar(&arEnabled, "enabled", true, "whether the rule is active")
+}
+
+func init() {
+	addAutoReplyFlags(autoReplyAddCmd)
+	addAutoReplyFlags(autoReplyEditCmd)
+	autoReplyListCmd.Flags().BoolVar(&arJSON, "json", false, "output JSON")
+	autoReplyTestCmd.Flags().Int64Var(&arChatID, "chat", 0, "chat ID to evaluate chat-scoped rules for")
+	autoReplyImportCmd.Flags().BoolVar(&arReplace, "replace", false, "delete existing rules before importing")
+
+	AutoReplyCmd.AddCommand(autoReplyListCmd)
+	AutoReplyCmd.AddCommand(autoReplyAddCmd)
+	AutoReplyCmd.AddCommand(autoReplyEditCmd)
+	AutoReplyCmd.AddCommand(autoReplyRmCmd)
+	AutoReplyCmd.AddCommand(autoReplyTestCmd)
+	AutoReplyCmd.AddCommand(autoReplyImportCmd)
+	AutoReplyCmd.AddCommand(autoReplyExportCmd)
+}
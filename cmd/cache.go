@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nathfavour/ideasbglobot/internal"
+)
+
+var (
+	cacheChatID  int64
+	cacheTop     int
+	cacheJSON    bool
+	cacheExpired bool
+)
+
+var CacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and clear the cache of reusable AI answers",
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache size, reuse and the most reused answers",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		stats, err := internal.ComputeCacheStats(cacheChatID, cacheTop)
+		if err != nil {
+			fmt.Printf("Error computing cache stats: %v\n", err)
+			return
+		}
+		if cacheJSON {
+			printJSON(stats)
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Enabled\t%t\n", stats.Enabled)
+		fmt.Fprintf(tw, "TTL\t%s\n", stats.TTL)
+		fmt.Fprintf(tw, "Entries\t%d / %d (%d expired)\n", stats.Entries, stats.MaxEntries, stats.Expired)
+		fmt.Fprintf(tw, "Size\t%s\n", internal.FormatBytes(stats.Bytes))
+		fmt.Fprintf(tw, "Hits\t%d (%.0f%% of lookups)\n", stats.Hits, stats.HitRate()*100)
+		if len(stats.Top) > 0 {
+			fmt.Fprintln(tw)
+			fmt.Fprintln(tw, "HITS\tCHAT\tMODEL\tCREATED\tPROMPT")
+			for _, e := range stats.Top {
+				chat := "shared"
+				if e.ChatID != 0 {
+					chat = fmt.Sprint(e.ChatID)
+				}
+				prompt := []rune(e.Prompt)
+				if len(prompt) > 60 {
+					prompt = append(prompt[:60], '…')
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", e.Hits, chat, e.Model, e.Created.Local().Format("2006-01-02 15:04"), string(prompt))
+			}
+		}
+		tw.Flush()
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete cached answers (all, one chat's, or only expired ones)",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		n, err := internal.ClearCache(cacheChatID, cacheExpired)
+		if err != nil {
+			fmt.Printf("Error clearing cache: %v\n", err)
+			return
+		}
+		fmt.Printf("Deleted %d cached answers\n", n)
+	},
+}
+
+func init() {
+	CacheCmd.PersistentFlags().Int64Var(&cacheChatID, "chat", 0, "only this chat's entries (default: the whole cache)")
+	cacheStatsCmd.Flags().IntVar(&cacheTop, "top", 10, "number of most reused entries to list")
+	cacheStatsCmd.Flags().BoolVar(&cacheJSON, "json", false, "output JSON")
+	cacheClearCmd.Flags().BoolVar(&cacheExpired, "expired", false, "only delete expired entries")
+	CacheCmd.AddCommand(cacheStatsCmd, cacheClearCmd)
+}
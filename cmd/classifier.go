@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nathfavour/ideasbglobot/internal"
+)
+
+var (
+	clsIncludeAI    bool
+	clsMinAIConf    float64
+	clsFolds        int
+	clsWithAIResult bool
+)
+
+var ClassifierCmd = &cobra.Command{
+	Use:   "classifier",
+	Short: "Train and evaluate the local message classifier",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var classifierTrainCmd = &cobra.Command{
+	Use:   "train",
+	Short: "Train the naive Bayes model from admin-labelled messages",
+	Run: func(cmd *cobra.Command, args []string) {
+		examples, err := internal.LabeledMessages(clsIncludeAI, clsMinAIConf)
+		if err != nil {
+			fmt.Printf("Error loading labelled messages: %v\n", err)
+			return
+		}
+		if len(examples) == 0 {
+			fmt.Println("No labelled messages yet. Correct labels with the buttons under bot replies, or use --include-ai.")
+			return
+		}
+		model := internal.TrainNaiveBayes(examples)
+		if err := internal.SaveLocalModel(model); err != nil {
+			fmt.Printf("Error saving model: %v\n", err)
+			return
+		}
+		labels := make([]string, 0, len(model.ClassDocs))
+		for label, n := range model.ClassDocs {
+			labels = append(labels, fmt.Sprintf("%s=%d", label, n))
+		}
+		sort.Strings(labels)
+		fmt.Printf("✅ Trained on %d messages (%s), vocabulary %d\n", model.Examples, strings.Join(labels, ", "), model.Vocabulary)
+	},
+}
+
+var classifierEvalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Cross-validate the model and report precision/recall per label",
+	Run: func(cmd *cobra.Command, args []string) {
+		examples, err := internal.LabeledMessages(clsIncludeAI, clsMinAIConf)
+		if err != nil {
+			fmt.Printf("Error loading labelled messages: %v\n", err)
+			return
+		}
+		report, err := internal.EvaluateNaiveBayes(examples, clsFolds)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		internal.WriteEvalReport(os.Stdout, report)
+	},
+}
+
+var classifierTestCmd = &cobra.Command{
+	Use:   "test <text>",
+	Short: "Show how each classifier labels a message",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		text := strings.Join(args, " ")
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		internal.EnsureAutoReplies()
+		names := []string{"rules", "local"}
+		classifiers := []internal.Classifier{internal.RulesClassifier{}, internal.LocalClassifier{Model: internal.LoadLocalModel()}}
+		if clsWithAIResult {
+			names = append(names, "ai")
+			classifiers = append(classifiers, internal.AIClassifier{Model: cfg.AIModel()})
+		}
+		names = append(names, "configured ("+cfg.Classifier+")")
+		classifiers = append(classifiers, internal.NewClassifier(cfg))
+		for i, cl := range classifiers {
+			res, err := cl.Classify(context.Background(), text)
+			if err != nil {
+				fmt.Printf("%-24s error: %v\n", names[i], err)
+				continue
+			}
+			fmt.Printf("%-24s %s (%.2f, %s)\n", names[i], res.Label, res.Confidence, res.Source)
+		}
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{classifierTrainCmd, classifierEvalCmd} {
+		c.Flags().BoolVar(&clsIncludeAI, "include-ai", false, "also learn from confident AI labels, not only admin corrections")
+		c.Flags().Float64Var(&clsMinAIConf, "min-ai-confidence", 0.8, "minimum AI confidence used with --include-ai")
+	}
+	classifierEvalCmd.Flags().IntVar(&clsFolds, "folds", 5, "number of cross-validation folds")
+	classifierTestCmd.Flags().BoolVar(&clsWithAIResult, "ai", false, "also ask the AI classifier")
+	ClassifierCmd.AddCommand(classifierTrainCmd, classifierEvalCmd, classifierTestCmd)
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nathfavour/ideasbglobot/internal"
+)
+
+var (
+	historyChatID int64
+	historyFormat string
+	historySince  string
+	historyUntil  string
+	historyOutput string
+	historyLimit  int
+	historyUserID int64
+	historyAll    bool
+)
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Export, import and search stored chat history",
+}
+
+var historyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export stored messages as json, jsonl, csv or markdown",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		since, err := internal.ParseTimeArg(historySince)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		until, err := internal.ParseTimeArg(historyUntil)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		msgs, err := internal.QueryMessages(internal.MessageFilter{ChatID: historyChatID, Since: since, Until: until})
+		if err != nil {
+			fmt.Printf("Error reading messages: %v\n", err)
+			return
+		}
+		var w io.Writer = os.Stdout
+		if historyOutput != "" && historyOutput != "-" {
+			f, err := os.Create(historyOutput)
+			if err != nil {
+				fmt.Printf("Error creating %s: %v\n", historyOutput, err)
+				return
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := internal.ExportMessages(w, msgs, historyFormat); err != nil {
+			fmt.Printf("Error exporting messages: %v\n", err)
+			return
+		}
+		if w != os.Stdout {
+			fmt.Printf("Exported %d messages to %s\n", len(msgs), historyOutput)
+		}
+	},
+}
+
+var historyImportCmd = &cobra.Command{
+	Use:   "import <result.json>",
+	Short: "Import a Telegram Desktop JSON export into the message history",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Printf("Error opening %s: %v\n", args[0], err)
+			return
+		}
+		defer f.Close()
+		res, err := internal.ImportTelegramExport(f, historyChatID)
+		if err != nil {
+			fmt.Printf("Error importing history: %v\n", err)
+			return
+		}
+		fmt.Printf("Imported %d messages from %d chat(s), skipped %d.\n", res.Imported, res.Chats, res.Skipped)
+	},
+}
+
+var historyRecallCmd = &cobra.Command{
+	Use:   "recall <question>",
+	Short: "Find the stored messages most similar to a question using embeddings",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		results, err := internal.SearchSimilarMessages(context.Background(), historyChatID, strings.Join(args, " "), historyLimit)
+		if err != nil {
+			fmt.Printf("Error searching history: %v\n", err)
+			return
+		}
+		fmt.Println(internal.FormatRecallResults(results))
+	},
+}
+
+var historyIndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Compute embeddings for all messages that are not indexed yet",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		total := 0
+		for {
+			n, err := internal.IndexPendingMessages(context.Background(), 50)
+			total += n
+			if err != nil {
+				fmt.Printf("Error indexing messages: %v\n", err)
+				break
+			}
+			if n == 0 {
+				break
+			}
+			fmt.Printf("Indexed %d messages...\n", total)
+		}
+		fmt.Printf("Done. %d messages indexed.\n", total)
+	},
+}
+
+var historyPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete stored messages and their derived data (recorded in the audit log)",
+	Run: func(cmd *cobra.Command, args []string) {
+		if historyChatID == 0 && historyUserID == 0 && historySince == "" && historyUntil == "" && !historyAll {
+			fmt.Println("Refusing to purge without a filter. Use --chat, --user, --since/--until, or --all.")
+			return
+		}
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		since, err := internal.ParseTimeArg(historySince)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		until, err := internal.ParseTimeArg(historyUntil)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		actor := "cli"
+		if u, err := user.Current(); err == nil {
+			actor = "cli:" + u.Username
+		}
+		var n int64
+		if historyUserID != 0 && since.IsZero() && until.IsZero() {
+			n, err = internal.ForgetUser(historyUserID, historyChatID, actor)
+		} else {
+			n, err = internal.PurgeMessages(internal.MessageFilter{ChatID: historyChatID, UserID: historyUserID, Since: since, Until: until}, actor)
+		}
+		if err != nil {
+			fmt.Printf("Error purging messages: %v\n", err)
+			return
+		}
+		fmt.Printf("Purged %d messages.\n", n)
+	},
+}
+
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Apply the configured retention policies now",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		n, err := internal.PruneMessages()
+		if err != nil {
+			fmt.Printf("Error pruning messages: %v\n", err)
+			return
+		}
+		fmt.Printf("Pruned %d messages.\n", n)
+	},
+}
+
+var historyAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show recent deletions from the audit log",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		entries, err := internal.RecentAuditEntries(historyLimit)
+		if err != nil {
+			fmt.Printf("Error reading audit log: %v\n", err)
+			return
+		}
+		for _, e := range entries {
+			fmt.Printf("%s  %-14s %-12s chat=%d user=%d deleted=%d %s\n",
+				e.Created.Local().Format("2006-01-02 15:04:05"), e.Actor, e.Action, e.ChatID, e.UserID, e.Count, e.Detail)
+		}
+	},
+}
+
+func init() {
+	historyExportCmd.Flags().Int64Var(&historyChatID, "chat", 0, "chat ID to export (default: all chats)")
+	historyExportCmd.Flags().StringVar(&historyFormat, "format", "json", "output format: "+strings.Join(internal.ExportFormats, "|"))
+	historyExportCmd.Flags().StringVar(&historySince, "since", "", "only messages at or after this time (YYYY-MM-DD, RFC3339 or duration like 7d)")
+	historyExportCmd.Flags().StringVar(&historyUntil, "until", "", "only messages before this time (YYYY-MM-DD, RFC3339 or duration like 7d)")
+	historyExportCmd.Flags().StringVarP(&historyOutput, "output", "o", "", "write to file instead of stdout")
+
+	historyImportCmd.Flags().Int64Var(&historyChatID, "chat", 0, "store messages under this chat ID instead of the one derived from the export")
+
+	historyRecallCmd.Flags().Int64Var(&historyChatID, "chat", 0, "only search this chat (default: all chats)")
+	historyRecallCmd.Flags().IntVar(&historyLimit, "limit", 5, "number of results")
+
+	historyPurgeCmd.Flags().Int64Var(&historyChatID, "chat", 0, "only purge this chat")
+	historyPurgeCmd.Flags().Int64Var(&historyUserID, "user", 0, "only purge this user's messages and derived data")
+	historyPurgeCmd.Flags().StringVar(&historySince, "since", "", "only messages at or after this time")
+	historyPurgeCmd.Flags().StringVar(&historyUntil, "until", "", "only messages before this time, e.g. --until 90d")
+	historyPurgeCmd.Flags().BoolVar(&historyAll, "all", false, "purge every stored message")
+
+	historyAuditCmd.Flags().IntVar(&historyLimit, "limit", 50, "number of entries")
+
+	HistoryCmd.AddCommand(historyExportCmd)
+	HistoryCmd.AddCommand(historyImportCmd)
+	HistoryCmd.AddCommand(historyRecallCmd)
+	HistoryCmd.AddCommand(historyIndexCmd)
+	HistoryCmd.AddCommand(historyPurgeCmd)
+	HistoryCmd.AddCommand(historyPruneCmd)
+	HistoryCmd.AddCommand(historyAuditCmd)
+}
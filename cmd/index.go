@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nathfavour/ideasbglobot/internal"
+)
+
+var indexInclude []string
+
+var IndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the knowledge sources used by /ask",
+}
+
+var indexListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered knowledge sources",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		if len(cfg.KnowledgeSources) == 0 {
+			fmt.Println("No knowledge sources registered. Add one with `ideasbglobot index add <name> <path>`.")
+			return
+		}
+		for _, src := range cfg.KnowledgeSources {
+			include := "default text files"
+			if len(src.Include) > 0 {
+				include = strings.Join(src.Include, ", ")
+			}
+			fmt.Printf("%-16s %s (%s)\n", src.Name, src.Path, include)
+		}
+	},
+}
+
+var indexAddCmd = &cobra.Command{
+	Use:   "add <name> <path>",
+	Short: "Register a repository or docs directory as a knowledge source",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		path, err := filepath.Abs(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		src := internal.KnowledgeSource{Name: args[0], Path: path, Include: indexInclude}
+		replaced := false
+		for i, existing := range cfg.KnowledgeSources {
+			if existing.Name == src.Name {
+				cfg.KnowledgeSources[i] = src
+				replaced = true
+			}
+		}
+		if !replaced {
+			cfg.KnowledgeSources = append(cfg.KnowledgeSources, src)
+		}
+		if err := saveCurrentConfig(cfg); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+		fmt.Printf("Knowledge source '%s' registered. Run `ideasbglobot index rebuild %s` to index it.\n", src.Name, src.Name)
+	},
+}
+
+var indexRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Unregister a knowledge source",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		kept := cfg.KnowledgeSources[:0]
+		for _, src := range cfg.KnowledgeSources {
+			if src.Name != args[0] {
+				kept = append(kept, src)
+			}
+		}
+		if len(kept) == len(cfg.KnowledgeSources) {
+			fmt.Printf("No knowledge source named '%s'.\n", args[0])
+			return
+		}
+		cfg.KnowledgeSources = kept
+		if err := saveCurrentConfig(cfg); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+		if err := internal.EnsureDatabase(); err == nil {
+			internal.DeleteKnowledgeSource(args[0])
+		}
+		fmt.Printf("Knowledge source '%s' removed.\n", args[0])
+	},
+}
+
+var indexRebuildCmd = &cobra.Command{
+	Use:   "rebuild [name]",
+	Short: "Chunk and embed the files of one or all knowledge sources",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		stats, err := internal.RebuildKnowledgeIndex(context.Background(), name, func(path string) {
+			fmt.Printf("  indexing %s\n", path)
+		})
+		for _, st := range stats {
+			fmt.Printf("%s: %d files, %d chunks\n", st.Source, st.Files, st.Chunks)
+		}
+		if err != nil {
+			fmt.Printf("Error rebuilding index: %v\n", err)
+		}
+	},
+}
+
+// saveCurrentConfig writes cfg back to the default config path.
+func saveCurrentConfig(cfg *internal.Configs) error {
+	path, err := internal.GetConfigPath()
+	if err != nil {
+		return err
+	}
+	return internal.SaveConfig(path, cfg)
+}
+
+func init() {
+	indexAddCmd.Flags().StringSliceVar(&indexInclude, "include", nil, "file name globs to index, e.g. --include '*.go,*.md'")
+
+	IndexCmd.AddCommand(indexListCmd)
+	IndexCmd.AddCommand(indexAddCmd)
+	IndexCmd.AddCommand(indexRmCmd)
+	IndexCmd.AddCommand(indexRebuildCmd)
+}
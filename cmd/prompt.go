@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nathfavour/ideasbglobot/internal"
+)
+
+var (
+	promptType   string
+	promptChatID int64
+	promptUser   string
+	promptRun    bool
+)
+
+var PromptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "List, inspect and test the prompt library in configs.json",
+}
+
+var promptListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List prompts and the message types mapped to them",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		types := map[string][]string{}
+		for _, label := range internal.Labels {
+			name := internal.PromptForType(cfg, label)
+			types[name] = append(types[name], label)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTYPES\tFIRST LINE")
+		for _, name := range internal.PromptNames(cfg) {
+			text, _ := internal.LookupPrompt(cfg, name)
+			first, _, _ := strings.Cut(text, "\n")
+			if r := []rune(first); len(r) > 60 {
+				first = string(r[:60]) + "…"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, strings.Join(types[name], ","), first)
+		}
+		tw.Flush()
+	},
+}
+
+var promptShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print a prompt template",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		text, ok := internal.LookupPrompt(cfg, args[0])
+		if !ok {
+			fmt.Printf("No prompt named %q\n", args[0])
+			return
+		}
+		fmt.Println(text)
+	},
+}
+
+var promptTestCmd = &cobra.Command{
+	Use:   "test <name> <message>",
+	Short: "Render a prompt for a sample message, optionally sending it to the model",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := internal.EnsureConfigFile()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		text, ok := internal.LookupPrompt(cfg, args[0])
+		if !ok {
+			fmt.Printf("No prompt named %q\n", args[0])
+			return
+		}
+		data := internal.PromptData{
+			Type:    internal.NormalizeLabel(promptType),
+			Chat:    "CLI test",
+			ChatID:  promptChatID,
+			User:    promptUser,
+			Message: strings.Join(args[1:], " "),
+			Time:    time.Now().Format("2006-01-02 15:04"),
+		}
+		if promptChatID != 0 {
+			if err := internal.EnsureDatabase(); err != nil {
+				fmt.Printf("Failed to initialize database: %v\n", err)
+				return
+			}
+			data.Context = internal.RecentContext(promptChatID, 0, 6)
+		}
+		prompt, err := internal.RenderPrompt(text, data)
+		if err != nil {
+			fmt.Printf("Error rendering prompt: %v\n", err)
+			return
+		}
+		fmt.Println(prompt)
+		if !promptRun {
+			return
+		}
+		fmt.Println("\n---")
+		reply, err := internal.OllamaChatWithModel(prompt, cfg.AIModel())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Println(reply)
+	},
+}
+
+func init() {
+	promptTestCmd.Flags().StringVar(&promptType, "type", internal.LabelQuestion, "message type to render with")
+	promptTestCmd.Flags().Int64Var(&promptChatID, "chat", 0, "chat ID to take recent context from")
+	promptTestCmd.Flags().StringVar(&promptUser, "user", "tester", "sender name to render with")
+	promptTestCmd.Flags().BoolVar(&promptRun, "run", false, "send the rendered prompt to the default model")
+	PromptCmd.AddCommand(promptListCmd, promptShowCmd, promptTestCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nathfavour/ideasbglobot/internal"
+)
+
+var (
+	statsChatID int64
+	statsPeriod string
+	statsJSON   bool
+)
+
+var StatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Report message, user and AI activity for a chat or all chats",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		since, label, err := internal.ParseStatsPeriod(statsPeriod)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		stats, err := internal.ComputeChatStats(statsChatID, since, label)
+		if err != nil {
+			fmt.Printf("Error computing stats: %v\n", err)
+			return
+		}
+		if statsJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(stats)
+			return
+		}
+		internal.WriteChatStatsTable(os.Stdout, stats)
+	},
+}
+
+var statsAICmd = &cobra.Command{
+	Use:   "ai",
+	Short: "Report AI requests and token usage by model, feature, chat and user",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := internal.EnsureDatabase(); err != nil {
+			fmt.Printf("Failed to initialize database: %v\n", err)
+			return
+		}
+		since, label, err := internal.ParseStatsPeriod(statsPeriod)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		report, err := internal.ComputeAIUsage(statsChatID, since, label)
+		if err != nil {
+			fmt.Printf("Error computing AI usage: %v\n", err)
+			return
+		}
+		if statsJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+			return
+		}
+		internal.WriteAIUsageTable(os.Stdout, report)
+	},
+}
+
+func init() {
+	StatsCmd.PersistentFlags().Int64Var(&statsChatID, "chat", 0, "chat ID to report on (default: all chats)")
+	StatsCmd.PersistentFlags().StringVar(&statsPeriod, "period", "7d", "period to cover: today, 24h, 7d, 30d or all")
+	StatsCmd.PersistentFlags().BoolVar(&statsJSON, "json", false, "output JSON instead of a table")
+	StatsCmd.AddCommand(statsAICmd)
+}
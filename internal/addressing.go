@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// entityText returns the part of text covered by e. Telegram entity offsets
+// and lengths are measured in UTF-16 code units.
+func entityText(text string, e tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if e.Offset < 0 || e.Length < 0 || e.Offset+e.Length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+}
+
+// messageEntities returns the text (or caption) of m with its entities.
+func messageEntities(m *tgbotapi.Message) (string, []tgbotapi.MessageEntity) {
+	if m.Text != "" {
+		return m.Text, m.Entities
+	}
+	return m.Caption, m.CaptionEntities
+}
+
+// isSelfUsername reports whether name ("@bot" or "bot") is the bot's username.
+func isSelfUsername(self tgbotapi.User, name string) bool {
+	return self.UserName != "" && strings.EqualFold(strings.TrimPrefix(name, "@"), self.UserName)
+}
+
+// isAddressedToBot reports whether m is meant for this bot: a private chat,
+// a @mention or text_mention of the bot, a /command@thisbot, or a reply to
+// one of the bot's own messages.
+func isAddressedToBot(self tgbotapi.User, m *tgbotapi.Message) bool {
+	if m.Chat != nil && m.Chat.IsPrivate() {
+		return true
+	}
+	if r := m.ReplyToMessage; r != nil && r.From != nil && r.From.ID == self.ID {
+		return true
+	}
+	text, entities := messageEntities(m)
+	for _, e := range entities {
+		switch e.Type {
+		case "mention":
+			if isSelfUsername(self, entityText(text, e)) {
+				return true
+			}
+		case "text_mention":
+			if e.User != nil && e.User.ID == self.ID {
+				return true
+			}
+		case "bot_command":
+			if _, at, ok := strings.Cut(entityText(text, e), "@"); ok && isSelfUsername(self, at) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mediaAddressed reports whether an image or file in m was handed to the bot
+// for an answer: its caption uses /ai, or it is addressed to the bot in a
+// chat whose mode allows replies to mentions.
+func mediaAddressed(self tgbotapi.User, m *tgbotapi.Message, settings ChatSettings) bool {
+	if aiCommandRe.MatchString(m.Caption) {
+		return true
+	}
+	switch settings.Mode {
+	case ModeSilent, ModeCommandsOnly:
+		return false
+	}
+	return isAddressedToBot(self, m)
+}
+
+// stripBotAddress removes /ai and mentions of the bot from text, leaving what
+// the sender asked.
+func stripBotAddress(self tgbotapi.User, text string) string {
+	var words []string
+	for _, w := range strings.Fields(text) {
+		if strings.EqualFold(w, "/ai") || isSelfUsername(self, w) {
+			continue
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// isCommandForOtherBot reports whether m is a /command@otherbot, which this
+// bot should ignore in groups with several bots.
+func isCommandForOtherBot(self tgbotapi.User, m *tgbotapi.Message) bool {
+	_, at, ok := strings.Cut(m.CommandWithAt(), "@")
+	return ok && !isSelfUsername(self, at)
+}
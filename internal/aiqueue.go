@@ -0,0 +1,253 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Queue errors returned by AI calls that could not get a slot.
+var (
+	ErrAIBusy      = errors.New("the AI is busy")
+	ErrAIQueueFull = errors.New("too many AI requests are waiting")
+	ErrAIUserLimit = errors.New("you already have AI requests waiting")
+)
+
+const (
+	defaultAIConcurrency = 1
+	defaultAIPerUser     = 2
+	defaultAIMaxWaiting  = 20
+)
+
+// AITask describes who an AI call is for, so the queue can order, limit and
+// cancel it. Attach it to the call's context with WithAITask.
+type AITask struct {
+	ChatID   int64
+	UserID   int64
+	Kind     string
+	Priority int  // higher runs first; admins and DMs get 1
+	NoWait   bool // fail with ErrAIBusy instead of waiting for a slot
+
+	// OnWait is called with the 1-based queue position while waiting and with
+	// 0 when the call starts. It is only called if the call has to wait.
+	OnWait func(position int)
+}
+
+type aiTaskKey struct{}
+
+// WithAITask attaches t to ctx for the AI queue.
+func WithAITask(ctx context.Context, t AITask) context.Context {
+	return context.WithValue(ctx, aiTaskKey{}, t)
+}
+
+type aiJob struct {
+	task    AITask
+	seq     int64
+	ready   chan struct{}
+	cancel  context.CancelFunc
+	lastPos int
+
+	// waitMu serializes the job's OnWait calls; once started is set, position
+	// updates still in flight from other goroutines are dropped, so they
+	// can't re-post a queue message after OnWait(0) removed it.
+	waitMu  sync.Mutex
+	started bool
+}
+
+// onWait reports position to the job's OnWait callback, unless the job has
+// already started. Position 0 marks it started.
+func (j *aiJob) onWait(position int) {
+	if j.task.OnWait == nil {
+		return
+	}
+	j.waitMu.Lock()
+	defer j.waitMu.Unlock()
+	if j.started {
+		return
+	}
+	j.started = position == 0
+	j.task.OnWait(position)
+}
+
+// aiQueue limits how many AI calls run at once. Waiting calls are ordered by
+// priority, then arrival.
+type aiQueue struct {
+	mu      sync.Mutex
+	running []*aiJob
+	waiting []*aiJob
+	seq     int64
+}
+
+var defaultAIQueue = &aiQueue{}
+
+func aiQueueLimits() (concurrency, perUser, maxWaiting int) {
+	cfg := currentConfig().AIQueue
+	concurrency, perUser, maxWaiting = cfg.Concurrency, cfg.PerUser, cfg.MaxWaiting
+	if concurrency <= 0 {
+		concurrency = defaultAIConcurrency
+	}
+	if perUser <= 0 {
+		perUser = defaultAIPerUser
+	}
+	if maxWaiting <= 0 {
+		maxWaiting = defaultAIMaxWaiting
+	}
+	return
+}
+
+// acquire waits for a free slot. The returned context is cancelled by
+// CancelAIJobs; release must be called when the AI call is done.
+func (q *aiQueue) acquire(ctx context.Context) (context.Context, func(), error) {
+	task, _ := ctx.Value(aiTaskKey{}).(AITask)
+	ctx, cancel := context.WithCancel(ctx)
+	job := &aiJob{task: task, ready: make(chan struct{}), cancel: cancel}
+	concurrency, perUser, maxWaiting := aiQueueLimits()
+
+	q.mu.Lock()
+	if task.UserID != 0 && q.userJobs(task.UserID) >= perUser {
+		q.mu.Unlock()
+		cancel()
+		return nil, nil, ErrAIUserLimit
+	}
+	if len(q.running) < concurrency && len(q.waiting) == 0 {
+		q.running = append(q.running, job)
+		q.mu.Unlock()
+		return ctx, func() { q.release(job) }, nil
+	}
+	if task.NoWait {
+		q.mu.Unlock()
+		cancel()
+		return nil, nil, ErrAIBusy
+	}
+	if len(q.waiting) >= maxWaiting {
+		q.mu.Unlock()
+		cancel()
+		return nil, nil, ErrAIQueueFull
+	}
+	q.seq++
+	job.seq = q.seq
+	q.waiting = append(q.waiting, job)
+	sort.SliceStable(q.waiting, func(i, j int) bool {
+		a, b := q.waiting[i], q.waiting[j]
+		if a.task.Priority != b.task.Priority {
+			return a.task.Priority > b.task.Priority
+		}
+		return a.seq < b.seq
+	})
+	notify := q.positionUpdates()
+	q.mu.Unlock()
+	notify()
+
+	select {
+	case <-job.ready:
+		job.onWait(0)
+		return ctx, func() { q.release(job) }, nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		removed := q.removeWaiting(job)
+		notify := q.positionUpdates()
+		q.mu.Unlock()
+		notify()
+		if !removed {
+			// Promoted just as it was cancelled: give the slot back.
+			q.release(job)
+		} else {
+			job.onWait(0)
+		}
+		return nil, nil, ctx.Err()
+	}
+}
+
+// release frees job's slot and starts the next waiting jobs.
+func (q *aiQueue) release(job *aiJob) {
+	job.cancel()
+	concurrency, _, _ := aiQueueLimits()
+	q.mu.Lock()
+	for i, j := range q.running {
+		if j == job {
+			q.running = append(q.running[:i], q.running[i+1:]...)
+			break
+		}
+	}
+	for len(q.running) < concurrency && len(q.waiting) > 0 {
+		next := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.running = append(q.running, next)
+		close(next.ready)
+	}
+	notify := q.positionUpdates()
+	q.mu.Unlock()
+	notify()
+}
+
+func (q *aiQueue) removeWaiting(job *aiJob) bool {
+	for i, j := range q.waiting {
+		if j == job {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *aiQueue) userJobs(userID int64) int {
+	n := 0
+	for _, list := range [][]*aiJob{q.running, q.waiting} {
+		for _, j := range list {
+			if j.task.UserID == userID {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// positionUpdates records new queue positions and returns a function that
+// reports them; call it after unlocking so callbacks may block.
+func (q *aiQueue) positionUpdates() func() {
+	type update struct {
+		job *aiJob
+		pos int
+	}
+	var updates []update
+	for i, j := range q.waiting {
+		if j.task.OnWait != nil && j.lastPos != i+1 {
+			j.lastPos = i + 1
+			updates = append(updates, update{j, i + 1})
+		}
+	}
+	return func() {
+		for _, u := range updates {
+			u.job.onWait(u.pos)
+		}
+	}
+}
+
+// CancelAIJobs cancels the running and waiting AI calls of userID in chatID.
+// A zero userID cancels every call in the chat. It returns how many were cancelled.
+func CancelAIJobs(chatID, userID int64) int {
+	q := defaultAIQueue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, list := range [][]*aiJob{q.running, q.waiting} {
+		for _, j := range list {
+			if j.task.ChatID == chatID && (userID == 0 || j.task.UserID == userID) {
+				j.cancel()
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// FormatAIQueueStatus summarises the queue for /status.
+func FormatAIQueueStatus() string {
+	concurrency, _, _ := aiQueueLimits()
+	q := defaultAIQueue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return fmt.Sprintf("AI queue: %d/%d running, %d waiting", len(q.running), concurrency, len(q.waiting))
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// aiTaskFor describes an AI call made for m. Bot admins and private chats
+// jump ahead of group traffic.
+func aiTaskFor(m *tgbotapi.Message, kind string) AITask {
+	t := AITask{ChatID: m.Chat.ID, UserID: m.From.ID, Kind: kind}
+	if m.Chat.IsPrivate() || currentConfig().IsAdmin(m.From.ID) {
+		t.Priority = 1
+	}
+	return t
+}
+
+// queueNotifier returns an AITask.OnWait callback that keeps a single
+// "you're #N in line" message up to date and deletes it once the call starts.
+func queueNotifier(bot *tgbotapi.BotAPI, m *tgbotapi.Message) func(int) {
+	var mu sync.Mutex
+	sentID := 0
+	return func(position int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if position == 0 {
+			if sentID != 0 {
+				bot.Request(tgbotapi.NewDeleteMessage(m.Chat.ID, sentID))
+				sentID = 0
+			}
+			return
+		}
+		text := fmt.Sprintf("⏳ You're #%d in line, I'll answer as soon as I can. Send /stop to cancel.", position)
+		if sentID != 0 {
+			bot.Request(tgbotapi.NewEditMessageText(m.Chat.ID, sentID, text))
+			return
+		}
+		msg := tgbotapi.NewMessage(m.Chat.ID, text)
+		msg.ReplyToMessageID = m.MessageID
+		if sent, err := bot.Send(msg); err == nil {
+			sentID = sent.MessageID
+		}
+	}
+}
+
+// aiLimitText returns the chat reply for an AI error caused by the queue, a
+// daily quota or /stop. ok is false for other errors; a cancelled call has no
+// reply.
+func aiLimitText(err error) (text string, ok bool) {
+	var quota *QuotaError
+	switch {
+	case errors.As(err, &quota):
+		return quota.Message(), true
+	case errors.Is(err, context.Canceled):
+		return "", true
+	case errors.Is(err, ErrAIUserLimit):
+		return "✋ You already have AI requests in progress. Wait for them to finish or send /stop.", true
+	case errors.Is(err, ErrAIQueueFull), errors.Is(err, ErrAIBusy):
+		return "🚦 I'm handling too many AI requests right now. Please try again in a minute.", true
+	}
+	return "", false
+}
+
+// handleStopCommand cancels the sender's running and queued AI requests in
+// this chat; chat admins can cancel everyone's with "/stop all".
+func handleStopCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	userID := m.From.ID
+	if strings.EqualFold(strings.TrimSpace(args), "all") {
+		if !isChatAdmin(bot, m.Chat.ID, m.From.ID) {
+			bot.Send(tgbotapi.NewMessage(m.Chat.ID, "⛔ Only chat admins can stop everyone's requests."))
+			return
+		}
+		userID = 0
+	}
+	n := CancelAIJobs(m.Chat.ID, userID)
+	text := "Nothing to stop."
+	if n > 0 {
+		log.Printf("[AI] /stop by %d cancelled %d request(s) in chat %d", m.From.ID, n, m.Chat.ID)
+		text = fmt.Sprintf("🛑 Stopped %d request(s).", n)
+	}
+	reply := tgbotapi.NewMessage(m.Chat.ID, text)
+	reply.ReplyToMessageID = m.MessageID
+	bot.Send(reply)
+}
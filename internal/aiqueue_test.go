@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useQueueConfig makes aiQueueLimits read c for the rest of the test.
+func useQueueConfig(t *testing.T, c AIQueueConfig) {
+	t.Helper()
+	old := botConfig
+	botConfig = &Configs{AIQueue: c}
+	t.Cleanup(func() { botConfig = old })
+}
+
+// queueJobs returns how many jobs q is running and holding.
+func queueJobs(q *aiQueue) (running, waiting int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.running), len(q.waiting)
+}
+
+// waitForJobs fails the test unless q reaches n running plus waiting jobs.
+func waitForJobs(t *testing.T, q *aiQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r, w := queueJobs(q)
+		if r+w == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue has %d running and %d waiting jobs, want %d in total", r, w, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// hold acquires a slot for task in the background and keeps it, or its place
+// in line, until the test ends.
+func hold(t *testing.T, q *aiQueue, task AITask) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(WithAITask(context.Background(), task))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, release, err := q.acquire(ctx)
+		if err != nil {
+			return
+		}
+		<-ctx.Done()
+		release()
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestAIQueuePriorityOrder(t *testing.T) {
+	useQueueConfig(t, AIQueueConfig{Concurrency: 1, PerUser: 10, MaxWaiting: 10})
+	tests := []struct {
+		name       string
+		priorities []int
+		want       []int // indexes into priorities, in the order they run
+	}{
+		{"same priority runs in arrival order", []int{0, 0, 0}, []int{0, 1, 2}},
+		{"higher priority jumps ahead", []int{0, 1, 0, 2}, []int{3, 1, 0, 2}},
+		{"background work waits for everyone", []int{-1, 0, 1}, []int{2, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &aiQueue{}
+			_, release, err := q.acquire(context.Background()) // occupy the only slot
+			if err != nil {
+				t.Fatal(err)
+			}
+			var mu sync.Mutex
+			var order []int
+			var wg sync.WaitGroup
+			for i, p := range tt.priorities {
+				wg.Add(1)
+				go func(i, p int) {
+					defer wg.Done()
+					_, done, err := q.acquire(WithAITask(context.Background(), AITask{Priority: p}))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					order = append(order, i)
+					mu.Unlock()
+					done()
+				}(i, p)
+				waitForJobs(t, q, i+2) // queue one at a time so arrival order is known
+			}
+			release()
+			wg.Wait()
+			if !reflect.DeepEqual(order, tt.want) {
+				t.Errorf("run order = %v, want %v", order, tt.want)
+			}
+		})
+	}
+}
+
+func TestAIQueueLimits(t *testing.T) {
+	useQueueConfig(t, AIQueueConfig{Concurrency: 1, PerUser: 2, MaxWaiting: 2})
+	tests := []struct {
+		name    string
+		queued  []AITask // hold these first: the first runs, the rest wait
+		task    AITask
+		wantErr error // context.DeadlineExceeded means the task was let into the line
+	}{
+		{"free slot", nil, AITask{UserID: 7}, nil},
+		{"one job of the user's own", []AITask{{UserID: 7}}, AITask{UserID: 7}, context.DeadlineExceeded},
+		{"per-user limit", []AITask{{UserID: 7}, {UserID: 7}}, AITask{UserID: 7}, ErrAIUserLimit},
+		{"other users are not limited", []AITask{{UserID: 7}, {UserID: 7}}, AITask{UserID: 8}, context.DeadlineExceeded},
+		{"untracked calls are not limited", []AITask{{}, {}}, AITask{}, context.DeadlineExceeded},
+		{"no wait when busy", []AITask{{UserID: 7}}, AITask{UserID: 8, NoWait: true}, ErrAIBusy},
+		{"queue full", []AITask{{UserID: 1}, {UserID: 2}, {UserID: 3}}, AITask{UserID: 4}, ErrAIQueueFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &aiQueue{}
+			for i, task := range tt.queued {
+				hold(t, q, task)
+				waitForJobs(t, q, i+1)
+			}
+			ctx, cancel := context.WithTimeout(WithAITask(context.Background(), tt.task), 20*time.Millisecond)
+			defer cancel()
+			_, release, err := q.acquire(ctx)
+			if err == nil {
+				release()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("acquire error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAIQueueCancelWhileWaiting(t *testing.T) {
+	useQueueConfig(t, AIQueueConfig{Concurrency: 1, PerUser: 10, MaxWaiting: 10})
+	tests := []struct {
+		name   string
+		cancel func(context.CancelFunc)
+	}{
+		{"caller gives up", func(cancel context.CancelFunc) { cancel() }},
+		{"/stop", func(context.CancelFunc) { CancelAIJobs(42, 7) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := defaultAIQueue // CancelAIJobs works on the bot's queue
+			hold(t, q, AITask{ChatID: 42, UserID: 8})
+			waitForJobs(t, q, 1)
+
+			var mu sync.Mutex
+			var positions []int
+			task := AITask{ChatID: 42, UserID: 7, OnWait: func(pos int) {
+				mu.Lock()
+				positions = append(positions, pos)
+				mu.Unlock()
+			}}
+			ctx, cancel := context.WithCancel(WithAITask(context.Background(), task))
+			defer cancel()
+			errc := make(chan error, 1)
+			go func() {
+				_, _, err := q.acquire(ctx)
+				errc <- err
+			}()
+			waitForJobs(t, q, 2)
+			tt.cancel(cancel)
+			if err := <-errc; !errors.Is(err, context.Canceled) {
+				t.Fatalf("acquire error = %v, want context.Canceled", err)
+			}
+			if _, waiting := queueJobs(q); waiting != 0 {
+				t.Errorf("%d jobs still waiting after cancel", waiting)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if want := []int{1, 0}; !reflect.DeepEqual(positions, want) {
+				t.Errorf("OnWait positions = %v, want %v", positions, want)
+			}
+		})
+	}
+}
+
+func TestAIJobDropsUpdatesAfterStart(t *testing.T) {
+	var positions []int
+	j := &aiJob{task: AITask{OnWait: func(pos int) { positions = append(positions, pos) }}}
+	for _, pos := range []int{2, 1, 0, 1} { // the last is a stale update from another goroutine
+		j.onWait(pos)
+	}
+	if want := []int{2, 1, 0}; !reflect.DeepEqual(positions, want) {
+		t.Errorf("OnWait positions = %v, want %v", positions, want)
+	}
+}
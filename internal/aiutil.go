@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,31 @@ import (
 	"strings"
 )
 
+// OllamaRequest is the body of an Ollama /api/generate call.
+type OllamaRequest struct {
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	System  string                 `json:"system,omitempty"`
+	Format  string                 `json:"format,omitempty"` // "json" forces a JSON response
+	Images  []string               `json:"images,omitempty"` // base64-encoded images for vision models
+	Options map[string]interface{} `json:"options,omitempty"`
+	Stream  bool                   `json:"stream"`
+}
+
+// OllamaResponse is a (non-streaming) /api/generate response. Durations are in nanoseconds.
+type OllamaResponse struct {
+	Model              string `json:"model"`
+	Response           string `json:"response"`
+	Done               bool   `json:"done"`
+	PromptEvalCount    int    `json:"prompt_eval_count"`
+	EvalCount          int    `json:"eval_count"`
+	TotalDuration      int64  `json:"total_duration"`
+	LoadDuration       int64  `json:"load_duration"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration"`
+	EvalDuration       int64  `json:"eval_duration"`
+	Error              string `json:"error"`
+}
+
 func OllamaChat(prompt string) (string, error) {
 	cfg, err := EnsureConfigFile()
 	if err != nil {
@@ -19,30 +45,41 @@ func OllamaChat(prompt string) (string, error) {
 }
 
 func OllamaChatWithModel(prompt, model string) (string, error) {
-	ollamaURL := "http://localhost:11434/api/generate"
-	payload := `{"model":` + jsonString(model) + `,"prompt":` + jsonString(prompt) + `,"stream":false}`
-	req, err := http.NewRequest("POST", ollamaURL, bytes.NewBuffer([]byte(payload)))
+	resp, err := GenerateWithFallback(context.Background(), OllamaRequest{Model: model, Prompt: prompt})
 	if err != nil {
 		return "", err
 	}
+	return strings.TrimSpace(resp.Response), nil
+}
+
+// OllamaGenerate sends a non-streaming generate request and returns the full response.
+func OllamaGenerate(ctx context.Context, r OllamaRequest) (*OllamaResponse, error) {
+	return ollamaGenerateAt(ctx, ollamaBaseURL, r)
+}
+
+func ollamaGenerateAt(ctx context.Context, baseURL string, r OllamaRequest) (*OllamaResponse, error) {
+	r.Stream = false
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/api/generate", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
-	type ollamaResp struct {
-		Response string `json:"response"`
-	}
-	var o ollamaResp
+	var o OllamaResponse
 	if err := json.Unmarshal(body, &o); err != nil {
-		return "", fmt.Errorf("ollama response: %s", string(body))
+		return nil, fmt.Errorf("ollama response: %s", string(body))
 	}
-	return strings.TrimSpace(o.Response), nil
-}
-
-func jsonString(s string) string {
-	b, _ := json.Marshal(s)
-	return string(b)
+	if o.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", o.Error)
+	}
+	return &o, nil
 }
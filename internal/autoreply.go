@@ -5,52 +5,379 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Keyword match modes for AutoReply.MatchMode.
+const (
+	MatchWord      = "word"      // keyword must appear as whole word(s); the default
+	MatchSubstring = "substring" // keyword may appear anywhere, even inside words
+	MatchFuzzy     = "fuzzy"     // like word, but tolerates small typos
 )
 
 type AutoReply struct {
-	ID       int    `json:"id"`
-	Category string `json:"category"`
-	Reply    string `json:"reply"`
-	Context  string `json:"context"`
+	ID        int      `json:"id"`
+	Category  string   `json:"category"`
+	Reply     string   `json:"reply"`
+	Context   string   `json:"context"`
+	Keywords  []string `json:"keywords,omitempty"`
+	Patterns  []string `json:"patterns,omitempty"`   // case-insensitive regular expressions
+	MatchMode string   `json:"match_mode,omitempty"` // word (default), substring or fuzzy
+	Priority  int      `json:"priority,omitempty"`   // higher priorities are tried first
+	Chats     []int64  `json:"chats,omitempty"`      // restrict to these chat IDs; empty means all chats
+	Enabled   *bool    `json:"enabled,omitempty"`    // nil means enabled
+
+	Replies         []string `json:"replies,omitempty"`          // extra reply variants; Reply is the first
+	Selection       string   `json:"selection,omitempty"`        // random (default), round_robin or weighted
+	Weights         []int    `json:"weights,omitempty"`          // per-variant weights for weighted selection
+	CooldownSeconds int      `json:"cooldown_seconds,omitempty"` // per-chat minimum gap between canned replies
+}
+
+// IsEnabled reports whether the rule is active.
+func (ar AutoReply) IsEnabled() bool {
+	return ar.Enabled == nil || *ar.Enabled
+}
+
+// AppliesTo reports whether the rule is scoped to chatID.
+func (ar AutoReply) AppliesTo(chatID int64) bool {
+	if len(ar.Chats) == 0 {
+		return true
+	}
+	for _, id := range ar.Chats {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+func autoRepliesPath() string {
+	return filepath.Join(GetAppDir(), "auto.json")
 }
 
+// defaultAutoReplies are written to a fresh auto.json and used to fill in
+// keywords for entries created before rules carried their own keywords.
+func defaultAutoReplies() []AutoReply {
+	return []AutoReply{
+		{
+			ID: 1, Category: "greeting", Priority: 10,
+			Reply: "👋 Hello {{.FirstName}}! I'm here to help with software engineering tasks.",
+			Replies: []string{
+				"👋 Hi {{.FirstName}}, what are you working on today?",
+				"🙌 Hey {{.FirstName}}! Ask me anything about code, bugs or features.",
+			},
+			Context:         "when users greet the bot",
+			Keywords:        []string{"hello", "hi", "hey", "good morning", "good evening"},
+			CooldownSeconds: 300,
+		},
+		{
+			ID: 2, Category: "issue", Priority: 30,
+			Reply: "🐛 I see you've mentioned an issue. Can you provide more details like steps to reproduce, expected vs actual behavior?",
+			Replies: []string{
+				"📝 Please create a detailed issue report with steps to reproduce.",
+				"🔍 Let's troubleshoot this {{.Keyword}}. What changed right before it started?",
+			},
+			Context:  "when users report bugs or issues",
+			Keywords: []string{"issue", "bug", "problem", "error", "crash", "broken"},
+			Patterns: []string{`\b(panic|exception|stack ?trace)\b`},
+		},
+		{
+			ID: 3, Category: LabelFeatureRequest, Priority: 20,
+			Reply: "💡 Interesting feature idea! Let's break it down. What's the main use case and expected outcome?",
+			Replies: []string{
+				"🚀 That sounds like a useful enhancement. Can you elaborate?",
+				"📋 I'll help you draft a proper feature request.",
+			},
+			Context:  "when users suggest new features",
+			Keywords: []string{"feature", "enhancement", "feature request"},
+			Patterns: []string{`\b(it would be (nice|great)|could we add|can we add)\b`},
+		},
+		{
+			ID: 4, Category: "question", Priority: 5,
+			Reply: "🤔 Good question! Let me help you with that. Can you provide more context?",
+			Replies: []string{
+				"📚 I can help you with that. What have you tried so far?",
+			},
+			Context:  "when users ask questions",
+			Keywords: []string{"question", "how", "what", "why", "?"},
+		},
+		{
+			ID: 5, Category: "code", Priority: 15,
+			Reply:    "💻 I can help with code review, debugging, or implementation suggestions. Share your code!",
+			Context:  "when users mention code-related topics",
+			Keywords: []string{"code", "review", "debug", "implementation", "refactor"},
+		},
+		{
+			ID: 6, Category: "default",
+			Reply: "👍 Noted! I'm tracking this conversation.",
+			Replies: []string{
+				"📊 I'm here to help with software engineering tasks.",
+				"🤖 How can I assist with your development work?",
+			},
+			Selection:       SelectRoundRobin,
+			Context:         "fallback when nothing else matches; has no keywords so it never triggers by itself",
+			CooldownSeconds: 600,
+		},
+		// Add more auto replies as needed...
+	}
+}
+
+// EnsureAutoReplies makes sure auto.json exists and seeds the auto_replies
+// table from it on first use.
 func EnsureAutoReplies() {
-	autoRepliesPath := filepath.Join(GetAppDir(), "auto.json")
-	dir := filepath.Dir(autoRepliesPath)
+	path := autoRepliesPath()
+	dir := filepath.Dir(path)
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		_ = os.MkdirAll(dir, 0700)
 	}
-	if _, err := os.Stat(autoRepliesPath); err == nil {
-		f, err := os.Open(autoRepliesPath)
-		if err == nil {
-			defer f.Close()
-			var replies []AutoReply
-			if json.NewDecoder(f).Decode(&replies) == nil && len(replies) > 0 {
-				return // valid
+	if replies := loadAutoRepliesFile(); len(replies) > 0 {
+		if upgradeAutoReplies(replies) {
+			if err := saveAutoReplies(replies); err != nil {
+				log.Printf("Error updating auto.json: %v", err)
+			} else {
+				log.Println("Added default keywords to auto.json entries")
 			}
 		}
+	} else {
+		GenerateAutoReplies()
+	}
+	if DB != nil {
+		if err := seedAutoRepliesFromFile(); err != nil {
+			log.Printf("Error importing auto.json into the database: %v", err)
+		}
 	}
-	GenerateAutoReplies()
 }
 
-func GenerateAutoReplies() {
-	autoRepliesPath := filepath.Join(GetAppDir(), "auto.json")
-	autoReplies := []AutoReply{
-		{1, "greeting", "👋 Hello! I'm here to help with software engineering tasks.", "when users greet the bot"},
-		{2, "issue", "🐛 I see you've mentioned an issue. Can you provide more details like steps to reproduce, expected vs actual behavior?", "when users report bugs or issues"},
-		{3, "feature", "💡 Interesting feature idea! Let's break it down. What's the main use case and expected outcome?", "when users suggest new features"},
-		{4, "question", "🤔 Good question! Let me help you with that. Can you provide more context?", "when users ask questions"},
-		{5, "code", "💻 I can help with code review, debugging, or implementation suggestions. Share your code!", "when users mention code-related topics"},
-		// Add more auto replies as needed...
+// upgradeAutoReplies fills in keywords for entries from older auto.json files,
+// which relied on keywords hardcoded per category, and maps category aliases
+// onto the message labels. It reports whether anything changed.
+func upgradeAutoReplies(replies []AutoReply) bool {
+	defaults := map[string]AutoReply{}
+	for _, d := range defaultAutoReplies() {
+		defaults[d.Category] = d
 	}
-	file, err := os.Create(autoRepliesPath)
-	if err != nil {
+	changed := false
+	for i, ar := range replies {
+		if label := NormalizeLabel(ar.Category); label != ar.Category && isKnownLabel(label) {
+			replies[i].Category = label
+			ar.Category = label
+			changed = true
+		}
+		d, ok := defaults[ar.Category]
+		if !ok || len(d.Keywords) == 0 || len(ar.Keywords) > 0 || len(ar.Patterns) > 0 {
+			continue
+		}
+		replies[i].Keywords = d.Keywords
+		replies[i].Patterns = d.Patterns
+		if ar.Priority == 0 {
+			replies[i].Priority = d.Priority
+		}
+		changed = true
+	}
+	return changed
+}
+
+func GenerateAutoReplies() {
+	if err := saveAutoReplies(defaultAutoReplies()); err != nil {
 		log.Printf("Error creating auto.json: %v", err)
 		return
 	}
+	log.Println("Generated auto.json with default replies")
+}
+
+func saveAutoReplies(replies []AutoReply) error {
+	file, err := os.Create(autoRepliesPath())
+	if err != nil {
+		return err
+	}
 	defer file.Close()
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	encoder.Encode(autoReplies)
-	log.Println("Generated auto.json with default replies")
+	return encoder.Encode(replies)
+}
+
+// loadAutoReplies returns the auto-reply rules from the database, which is
+// the source of truth, or from auto.json when the database is not open.
+func loadAutoReplies() []AutoReply {
+	if DB != nil {
+		rules, err := ListAutoReplies()
+		if err == nil {
+			return rules
+		}
+		log.Printf("Error loading auto-replies: %v", err)
+	}
+	return loadAutoRepliesFile()
+}
+
+// loadAutoRepliesFile loads auto.json from the app directory.
+func loadAutoRepliesFile() []AutoReply {
+	f, err := os.Open(autoRepliesPath())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var replies []AutoReply
+	if err := json.NewDecoder(f).Decode(&replies); err != nil {
+		return nil
+	}
+	return replies
+}
+
+// MatchAutoReply returns the highest-priority enabled rule that applies to
+// chatID and matches text, together with the keyword or pattern match that
+// triggered it. It returns nil when no rule matches.
+func MatchAutoReply(text string, chatID int64) (*AutoReply, string) {
+	return matchAutoReplyRules(loadAutoReplies(), text, chatID)
+}
+
+func matchAutoReplyRules(rules []AutoReply, text string, chatID int64) (*AutoReply, string) {
+	rules = append([]AutoReply(nil), rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	lower := strings.ToLower(text)
+	for i := range rules {
+		ar := &rules[i]
+		if !ar.IsEnabled() || !ar.AppliesTo(chatID) {
+			continue
+		}
+		for _, p := range ar.Patterns {
+			re := compileRulePattern(p)
+			if re == nil {
+				continue
+			}
+			if m := re.FindString(text); m != "" {
+				return ar, m
+			}
+		}
+		for _, kw := range ar.Keywords {
+			if matchKeyword(lower, strings.ToLower(strings.TrimSpace(kw)), ar.MatchMode) {
+				return ar, kw
+			}
+		}
+	}
+	return nil, ""
+}
+
+// matchKeyword reports whether the lowercased keyword occurs in the lowercased text.
+func matchKeyword(text, keyword, mode string) bool {
+	if keyword == "" {
+		return false
+	}
+	// Punctuation-only keywords such as "?" have no word boundaries to respect.
+	if mode == MatchSubstring || strings.IndexFunc(keyword, isWordRune) < 0 {
+		return strings.Contains(text, keyword)
+	}
+	if containsWords(text, keyword) {
+		return true
+	}
+	if mode == MatchFuzzy && !strings.ContainsRune(keyword, ' ') {
+		for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) }) {
+			if withinEditDistance(word, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsWords reports whether keyword appears in text delimited by non-word
+// characters, so "hi" matches "hi there" but not "this".
+func containsWords(text, keyword string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], keyword)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(keyword)
+		before := i == 0 || !isWordRune(lastRune(text[:i]))
+		after := end == len(text) || !isWordRune(firstRune(text[end:]))
+		if before && after {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
+func lastRune(s string) rune {
+	r := []rune(s)
+	return r[len(r)-1]
+}
+
+// withinEditDistance allows one typo for words of 4+ letters and two for 8+.
+func withinEditDistance(word, keyword string) bool {
+	n := len([]rune(keyword))
+	allowed := 0
+	switch {
+	case n >= 8:
+		allowed = 2
+	case n >= 4:
+		allowed = 1
+	}
+	if allowed == 0 {
+		return word == keyword
+	}
+	return levenshtein(word, keyword) <= allowed
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+var (
+	rulePatternMu    sync.Mutex
+	rulePatternCache = map[string]*regexp.Regexp{}
+)
+
+// compileRulePattern compiles a rule pattern case-insensitively, caching the
+// result. Invalid patterns are logged once and then ignored.
+func compileRulePattern(p string) *regexp.Regexp {
+	rulePatternMu.Lock()
+	defer rulePatternMu.Unlock()
+	if re, ok := rulePatternCache[p]; ok {
+		return re
+	}
+	re, err := regexp.Compile("(?i)" + p)
+	if err != nil {
+		log.Printf("Invalid auto-reply pattern %q: %v", p, err)
+		re = nil
+	}
+	rulePatternCache[p] = re
+	return re
 }
@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const autoReplyUsage = `Usage:
+/autoreply list
+/autoreply show <id>
+/autoreply add <category> <keyword,keyword> | <reply>
+/autoreply reply <id> <extra reply variant>
+/autoreply keywords <id> <keyword,keyword>
+/autoreply priority <id> <n>
+/autoreply enable <id> | disable <id>
+/autoreply rm <id>
+/autoreply test <text>`
+
+// handleAutoReplyCommand manages auto-reply rules from Telegram. Only bot
+// admins (Configs.Admins) may use it.
+func handleAutoReplyCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	if !botConfig.IsAdmin(m.From.ID) {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("⛔ Only bot admins can manage auto-replies. Add %d to \"admins\" in configs.json.", m.From.ID)))
+		return
+	}
+	sub, rest := splitFirstWord(args)
+	reply := func(text string) { sendLongMessage(bot, m.Chat.ID, text) }
+
+	switch sub {
+	case "list":
+		rules, err := ListAutoReplies()
+		if err != nil {
+			reply("[Auto-reply error] " + err.Error())
+			return
+		}
+		if len(rules) == 0 {
+			reply("No auto-replies defined.")
+			return
+		}
+		var b strings.Builder
+		for _, r := range rules {
+			state := ""
+			if !r.IsEnabled() {
+				state = " (disabled)"
+			}
+			fmt.Fprintf(&b, "#%d %s%s p%d: %s\n", r.ID, r.Category, state, r.Priority, strings.Join(r.Keywords, ", "))
+		}
+		reply(b.String())
+	case "show":
+		ar, err := autoReplyFromArg(rest)
+		if err != nil {
+			reply(err.Error())
+			return
+		}
+		reply(FormatAutoReply(*ar))
+	case "add":
+		spec, text, ok := strings.Cut(rest, "|")
+		category, keywords := splitFirstWord(spec)
+		if !ok || category == "" || strings.TrimSpace(text) == "" {
+			reply(autoReplyUsage)
+			return
+		}
+		ar := AutoReply{Category: category, Keywords: splitList(keywords), Reply: strings.TrimSpace(text)}
+		if err := SaveAutoReply(&ar); err != nil {
+			reply("[Auto-reply error] " + err.Error())
+			return
+		}
+		reply(fmt.Sprintf("✅ Added auto-reply #%d", ar.ID))
+	case "reply", "keywords", "priority", "enable", "disable":
+		idArg, value := splitFirstWord(rest)
+		ar, err := autoReplyFromArg(idArg)
+		if err != nil {
+			reply(err.Error())
+			return
+		}
+		switch sub {
+		case "reply":
+			if value == "" {
+				reply(autoReplyUsage)
+				return
+			}
+			ar.Replies = append(ar.Replies, value)
+		case "keywords":
+			ar.Keywords = splitList(value)
+		case "priority":
+			p, err := strconv.Atoi(value)
+			if err != nil {
+				reply("Priority must be a number.")
+				return
+			}
+			ar.Priority = p
+		case "enable", "disable":
+			enabled := sub == "enable"
+			ar.Enabled = &enabled
+		}
+		if err := SaveAutoReply(ar); err != nil {
+			reply("[Auto-reply error] " + err.Error())
+			return
+		}
+		reply("✅ Updated\n" + FormatAutoReply(*ar))
+	case "rm", "delete":
+		ar, err := autoReplyFromArg(rest)
+		if err != nil {
+			reply(err.Error())
+			return
+		}
+		if err := DeleteAutoReply(ar.ID); err != nil {
+			reply("[Auto-reply error] " + err.Error())
+			return
+		}
+		reply(fmt.Sprintf("🗑 Deleted auto-reply #%d", ar.ID))
+	case "test":
+		rule, keyword := MatchAutoReply(rest, m.Chat.ID)
+		if rule == nil {
+			reply("No rule matches.")
+			return
+		}
+		reply(fmt.Sprintf("Matched %q\n%s", keyword, FormatAutoReply(*rule)))
+	default:
+		reply(autoReplyUsage)
+	}
+}
+
+func autoReplyFromArg(arg string) (*AutoReply, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(arg), "#"))
+	if err != nil {
+		return nil, fmt.Errorf("Expected an auto-reply id, got %q", arg)
+	}
+	return FindAutoReply(id)
+}
+
+// splitFirstWord returns the first whitespace-separated word and the trimmed rest.
+func splitFirstWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, " \t\n")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i+1:])
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
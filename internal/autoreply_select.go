@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Reply selection strategies for AutoReply.Selection.
+const (
+	SelectRandom     = "random" // the default; avoids repeating the last reply in a chat
+	SelectRoundRobin = "round_robin"
+	SelectWeighted   = "weighted"
+)
+
+// AutoReplyData is available to reply templates, e.g. "Hi {{.Username}}!".
+type AutoReplyData struct {
+	Username  string
+	FirstName string
+	ChatTitle string
+	ChatID    int64
+	Time      string // local time as 15:04
+	Date      string // local date as 2006-01-02
+	Keyword   string // the keyword or pattern match that triggered the rule
+	Category  string
+}
+
+// Variants returns every reply text of the rule: Reply followed by Replies.
+func (ar AutoReply) Variants() []string {
+	var out []string
+	if strings.TrimSpace(ar.Reply) != "" {
+		out = append(out, ar.Reply)
+	}
+	for _, r := range ar.Replies {
+		if strings.TrimSpace(r) != "" {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+type replyState struct {
+	lastSent    time.Time
+	lastVariant int
+	next        int // round-robin position
+}
+
+var (
+	replyStateMu sync.Mutex
+	replyStates  = map[string]*replyState{}
+)
+
+// RenderAutoReply picks a reply variant for the rule in chatID and executes it
+// as a template with data. It returns false when the rule is cooling down in
+// that chat or has no reply text.
+func RenderAutoReply(ar *AutoReply, chatID int64, data AutoReplyData) (string, bool) {
+	variants := ar.Variants()
+	if len(variants) == 0 {
+		return "", false
+	}
+	replyStateMu.Lock()
+	key := fmt.Sprintf("%d/%d/%s", chatID, ar.ID, ar.Category)
+	st, ok := replyStates[key]
+	if !ok {
+		st = &replyState{lastVariant: -1}
+		replyStates[key] = st
+	}
+	if ar.CooldownSeconds > 0 && time.Since(st.lastSent) < time.Duration(ar.CooldownSeconds)*time.Second {
+		replyStateMu.Unlock()
+		return "", false
+	}
+	idx := pickVariant(ar, len(variants), st)
+	st.lastVariant = idx
+	st.lastSent = time.Now()
+	replyStateMu.Unlock()
+
+	data.Category = ar.Category
+	return executeReplyTemplate(variants[idx], data), true
+}
+
+func pickVariant(ar *AutoReply, n int, st *replyState) int {
+	if n == 1 {
+		return 0
+	}
+	switch ar.Selection {
+	case SelectRoundRobin:
+		idx := st.next % n
+		st.next = idx + 1
+		return idx
+	case SelectWeighted:
+		total := 0
+		for i := 0; i < n; i++ {
+			total += variantWeight(ar, i)
+		}
+		if total > 0 {
+			r := rand.Intn(total)
+			for i := 0; i < n; i++ {
+				if r -= variantWeight(ar, i); r < 0 {
+					return i
+				}
+			}
+		}
+		return rand.Intn(n)
+	default:
+		// Random, but never the same text twice in a row in one chat.
+		if st.lastVariant < 0 || st.lastVariant >= n {
+			return rand.Intn(n)
+		}
+		idx := rand.Intn(n - 1)
+		if idx >= st.lastVariant {
+			idx++
+		}
+		return idx
+	}
+}
+
+// variantWeight returns the weight of variant i; missing weights count as 1.
+func variantWeight(ar *AutoReply, i int) int {
+	if i < len(ar.Weights) {
+		if ar.Weights[i] < 0 {
+			return 0
+		}
+		return ar.Weights[i]
+	}
+	return 1
+}
+
+// executeReplyTemplate renders text with data, falling back to the raw text
+// if it is not a valid template.
+func executeReplyTemplate(text string, data AutoReplyData) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	tmpl, err := template.New("reply").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		log.Printf("Invalid auto-reply template %q: %v", text, err)
+		return text
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("Error rendering auto-reply template %q: %v", text, err)
+		return text
+	}
+	return buf.String()
+}
+
+// getAutoReply returns a canned reply for a message type that matched no
+// rule, using the auto.json rule of that category or the "default" category.
+func getAutoReply(category string, chatID int64, data AutoReplyData) (string, bool) {
+	rules := loadAutoReplies()
+	for _, want := range []string{category, "default"} {
+		for i := range rules {
+			ar := &rules[i]
+			if ar.Category == want && ar.IsEnabled() && ar.AppliesTo(chatID) {
+				return RenderAutoReply(ar, chatID, data)
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// autoReplyColumns are added to the auto_replies table created by InitDatabase;
+// list fields are stored as JSON text.
+var autoReplyColumns = [][2]string{
+	{"keywords", "TEXT"},
+	{"patterns", "TEXT"},
+	{"match_mode", "TEXT"},
+	{"priority", "INTEGER DEFAULT 0"},
+	{"chats", "TEXT"},
+	{"enabled", "BOOLEAN DEFAULT 1"},
+	{"replies", "TEXT"},
+	{"selection", "TEXT"},
+	{"weights", "TEXT"},
+	{"cooldown_seconds", "INTEGER DEFAULT 0"},
+}
+
+func initAutoReplyTable() error {
+	for _, col := range autoReplyColumns {
+		if err := ensureColumn("auto_replies", col[0], col[1]); err != nil {
+			return err
+		}
+	}
+	// Categories used to differ from message types ("feature" vs "feature_request").
+	_, err := DB.Exec(`UPDATE auto_replies SET category = ? WHERE category = 'feature'`, LabelFeatureRequest)
+	return err
+}
+
+// ListAutoReplies returns every stored auto-reply rule ordered by ID.
+func ListAutoReplies() ([]AutoReply, error) {
+	rows, err := DB.Query(`SELECT id, COALESCE(category, ''), COALESCE(reply, ''), COALESCE(context, ''),
+		keywords, patterns, COALESCE(match_mode, ''), COALESCE(priority, 0), chats, COALESCE(enabled, 1),
+		replies, COALESCE(selection, ''), weights, COALESCE(cooldown_seconds, 0)
+		FROM auto_replies ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AutoReply
+	for rows.Next() {
+		var ar AutoReply
+		var keywords, patterns, chats, replies, weights sql.NullString
+		var enabled bool
+		if err := rows.Scan(&ar.ID, &ar.Category, &ar.Reply, &ar.Context, &keywords, &patterns, &ar.MatchMode,
+			&ar.Priority, &chats, &enabled, &replies, &ar.Selection, &weights, &ar.CooldownSeconds); err != nil {
+			return nil, err
+		}
+		unmarshalColumn(keywords, &ar.Keywords)
+		unmarshalColumn(patterns, &ar.Patterns)
+		unmarshalColumn(chats, &ar.Chats)
+		unmarshalColumn(replies, &ar.Replies)
+		unmarshalColumn(weights, &ar.Weights)
+		if !enabled {
+			ar.Enabled = &enabled
+		}
+		out = append(out, ar)
+	}
+	return out, rows.Err()
+}
+
+// FindAutoReply returns the rule with the given ID.
+func FindAutoReply(id int) (*AutoReply, error) {
+	rules, err := ListAutoReplies()
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		if rules[i].ID == id {
+			return &rules[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no auto-reply with id %d", id)
+}
+
+// sqlExecer is implemented by both *sql.DB and *sql.Tx.
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// SaveAutoReply inserts the rule, or replaces the stored rule with the same ID.
+// New rules get an ID assigned when ar.ID is 0.
+func SaveAutoReply(ar *AutoReply) error {
+	return saveAutoReply(DB, ar)
+}
+
+func saveAutoReply(db sqlExecer, ar *AutoReply) error {
+	if strings.TrimSpace(ar.Category) == "" {
+		return fmt.Errorf("category is required")
+	}
+	for _, p := range ar.Patterns {
+		if compileRulePattern(p) == nil {
+			return fmt.Errorf("invalid pattern %q", p)
+		}
+	}
+	var id interface{}
+	if ar.ID != 0 {
+		id = ar.ID
+	}
+	res, err := db.Exec(`INSERT OR REPLACE INTO auto_replies
+		(id, category, reply, context, keywords, patterns, match_mode, priority, chats, enabled, replies, selection, weights, cooldown_seconds)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, ar.Category, ar.Reply, ar.Context, marshalColumn(ar.Keywords), marshalColumn(ar.Patterns), ar.MatchMode,
+		ar.Priority, marshalColumn(ar.Chats), ar.IsEnabled(), marshalColumn(ar.Replies), ar.Selection,
+		marshalColumn(ar.Weights), ar.CooldownSeconds)
+	if err != nil {
+		return err
+	}
+	if ar.ID == 0 {
+		newID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		ar.ID = int(newID)
+	}
+	return nil
+}
+
+// DeleteAutoReply removes the rule with the given ID.
+func DeleteAutoReply(id int) error {
+	res, err := DB.Exec(`DELETE FROM auto_replies WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no auto-reply with id %d", id)
+	}
+	return nil
+}
+
+// ImportAutoReplies loads rules from an auto.json file into the database.
+// With replace set, existing rules are removed first; otherwise rules with the
+// same ID are overwritten and the rest are kept. If any rule fails nothing is
+// changed.
+func ImportAutoReplies(path string, replace bool) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var rules []AutoReply
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return 0, fmt.Errorf("decode %s: %w", path, err)
+	}
+	upgradeAutoReplies(rules)
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if replace {
+		if _, err := tx.Exec(`DELETE FROM auto_replies`); err != nil {
+			return 0, err
+		}
+	}
+	for i := range rules {
+		if err := saveAutoReply(tx, &rules[i]); err != nil {
+			return 0, fmt.Errorf("rule %d: %w", rules[i].ID, err)
+		}
+	}
+	return len(rules), tx.Commit()
+}
+
+// ExportAutoReplies writes the stored rules to path in auto.json format.
+func ExportAutoReplies(path string) (int, error) {
+	rules, err := ListAutoReplies()
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return len(rules), enc.Encode(rules)
+}
+
+// seedAutoRepliesFromFile imports auto.json once, into an empty table, so
+// existing setups keep their replies after the switch to the database. From
+// then on the database is the source of truth: removing every rule does not
+// bring auto.json back.
+func seedAutoRepliesFromFile() error {
+	const migration = "seed_auto_replies"
+	if done, err := migrationDone(migration); err != nil || done {
+		return err
+	}
+	var n int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM auto_replies`).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := ImportAutoReplies(autoRepliesPath(), false); err != nil {
+			return err
+		}
+	}
+	return markMigrationDone(migration)
+}
+
+// FormatAutoReply renders a rule as a short multi-line description.
+func FormatAutoReply(ar AutoReply) string {
+	var b strings.Builder
+	state := ""
+	if !ar.IsEnabled() {
+		state = " (disabled)"
+	}
+	fmt.Fprintf(&b, "#%d %s%s, priority %d", ar.ID, ar.Category, state, ar.Priority)
+	if ar.MatchMode != "" && ar.MatchMode != MatchWord {
+		fmt.Fprintf(&b, ", %s match", ar.MatchMode)
+	}
+	if len(ar.Chats) > 0 {
+		fmt.Fprintf(&b, ", chats %v", ar.Chats)
+	}
+	if ar.CooldownSeconds > 0 {
+		fmt.Fprintf(&b, ", cooldown %ds", ar.CooldownSeconds)
+	}
+	if len(ar.Keywords) > 0 {
+		fmt.Fprintf(&b, "\n  keywords: %s", strings.Join(ar.Keywords, ", "))
+	}
+	if len(ar.Patterns) > 0 {
+		fmt.Fprintf(&b, "\n  patterns: %s", strings.Join(ar.Patterns, "  "))
+	}
+	for i, v := range ar.Variants() {
+		fmt.Fprintf(&b, "\n  reply %d: %s", i+1, v)
+	}
+	return b.String()
+}
+
+func marshalColumn(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return nil
+	}
+	return string(b)
+}
+
+func unmarshalColumn(s sql.NullString, v interface{}) {
+	if s.Valid && s.String != "" {
+		json.Unmarshal([]byte(s.String), v)
+	}
+}
@@ -2,7 +2,7 @@ package internal
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -19,6 +20,12 @@ import (
 // Global config cache for live updates
 var botConfig *Configs
 
+// aiCommandRe matches /ai anywhere in a message, which triggers an AI reply.
+var aiCommandRe = regexp.MustCompile(`/ai(\s|$|[^a-zA-Z0-9_])`)
+
+// aiModelSetRe matches the legacy "/ai ollama model set <model>" command.
+var aiModelSetRe = regexp.MustCompile(`/ai ollama model set [a-zA-Z0-9_\-.:]+`)
+
 func StartBot(token string) {
 	EnsureAutoReplies()
 
@@ -42,6 +49,12 @@ func StartBot(token string) {
 		cancel()
 	}()
 
+	go StartEmbeddingIndexer(ctx)
+	go StartRetentionPruner(ctx)
+	go StartAIHealthProber(ctx)
+	go StartDigestScheduler(ctx, bot)
+	go StartActionReminder(ctx, bot)
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 	updates := bot.GetUpdatesChan(u)
@@ -55,6 +68,10 @@ func StartBot(token string) {
 			bot.StopReceivingUpdates()
 			return
 		case update := <-updates:
+			if update.CallbackQuery != nil {
+				handleCallbackQuery(bot, update.CallbackQuery)
+				continue
+			}
 			if update.Message == nil {
 				continue
 			}
@@ -64,59 +81,85 @@ func StartBot(token string) {
 				username = update.Message.From.FirstName
 			}
 
-			msgType := detectMessageType(update.Message.Text)
+			// Photos and files carry their text in the caption.
+			text := update.Message.Text
+			if text == "" {
+				text = update.Message.Caption
+			}
+			media := messageMedia(update.Message)
+			_, _, hasImage := messageImage(update.Message)
+
+			label := classifyMessage(update.Message.Chat.ID, text)
+			msgType := label.Label
 			msg := Message{
-				ChatID:   update.Message.Chat.ID,
-				UserID:   update.Message.From.ID,
-				Username: username,
-				Text:     update.Message.Text,
-				IsBot:    update.Message.From.IsBot,
-				Type:     msgType,
-				Created:  time.Now(),
+				ChatID:    update.Message.Chat.ID,
+				MessageID: int64(update.Message.MessageID),
+				UserID:    update.Message.From.ID,
+				Username:  username,
+				Text:      text,
+				Media:     media,
+				IsBot:     update.Message.From.IsBot,
+				Type:      msgType,
+				Created:   time.Now(),
+
+				Confidence:  label.Confidence,
+				LabelSource: label.Source,
+			}
+			rowID, err := SaveMessage(msg)
+			if err != nil {
+				log.Printf("Error saving message: %v", err)
+			} else {
+				NotifyIndexer()
+				go relabelMessage(rowID, update.Message.Chat.ID, update.Message.From.ID, text, label)
 			}
-			SaveMessage(msg)
 
 			log.Printf("[%s] Chat: %d, User: %s, Text: %s",
-				strings.ToUpper(msgType), update.Message.Chat.ID, username, update.Message.Text)
+				strings.ToUpper(msgType), update.Message.Chat.ID, username, text)
 
-			// AI model set command: /ai ollama model set <modelname>
-			if matched, _ := regexp.MatchString(`/ai ollama model set [a-zA-Z0-9_\-]+`, update.Message.Text); matched {
+			if isCommandForOtherBot(bot.Self, update.Message) {
+				continue
+			}
+
+			// Silent chats only answer the commands needed to change that.
+			settings := GetChatSettings(update.Message.Chat.ID)
+			if settings.Mode == ModeSilent && !(update.Message.IsCommand() &&
+				(update.Message.Command() == "mode" || update.Message.Command() == "forgetme" || update.Message.Command() == "stop")) {
+				continue
+			}
+
+			if hasImage {
+				if mediaAddressed(bot.Self, update.Message, settings) {
+					go handleImageMessage(ctx, bot, update.Message, msgType)
+				}
+				continue
+			}
+			if update.Message.Document != nil {
+				go handleDocumentMessage(ctx, bot, update.Message, msgType, settings)
+				continue
+			}
+
+			// AI model set command: /ai ollama model set <modelname> sets this chat's model
+			if aiModelSetRe.MatchString(update.Message.Text) {
 				parts := strings.Fields(update.Message.Text)
-				for i := 0; i < len(parts)-3; i++ {
+				for i := 0; i < len(parts)-4; i++ {
 					if parts[i] == "/ai" && parts[i+1] == "ollama" && parts[i+2] == "model" && parts[i+3] == "set" {
 						model := parts[i+4]
-						botConfig.DefaultAIModel = model
-						// Save config live
-						configPath, _ := GetConfigPath()
-						SaveConfig(configPath, botConfig)
-						reply := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("✅ Default AI model set to '%s' (will be used for next /ai)", model))
-						bot.Send(reply)
-						continue
+						text := fmt.Sprintf("✅ AI model for this chat set to '%s' (will be used for next /ai)", model)
+						if !update.Message.Chat.IsPrivate() && !isChatAdmin(bot, update.Message.Chat.ID, update.Message.From.ID) {
+							text = "⛔ Only chat admins can change this chat's model. Use /settings me model <name> for yourself."
+						} else if err := SetAIOverride(ScopeChat, update.Message.Chat.ID, "model", model); err != nil {
+							text = "[Settings error] " + err.Error()
+						}
+						bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text))
+						break
 					}
 				}
+				continue
 			}
 
 			// /ai anywhere in the message triggers AI reply
-			aiRe := regexp.MustCompile(`/ai(\s|$|[^a-zA-Z0-9_])`)
-			if aiRe.MatchString(update.Message.Text) {
-				prompt := botConfig.DefaultAIPrompt
-				if prompt == "" {
-					prompt = "Reply in one concise sentence. Use two only if absolutely necessary, and use as few words as possible."
-				}
-				model := botConfig.DefaultAIModel
-				if model == "" {
-					model = "llama2"
-				}
-				userMsg := update.Message.Text
-				aiPrompt := prompt + "\n\nUser message: " + userMsg
-				response, err := OllamaChatWithModel(aiPrompt, model)
-				if err != nil {
-					reply := tgbotapi.NewMessage(update.Message.Chat.ID, "[AI error] "+err.Error())
-					bot.Send(reply)
-				} else {
-					reply := tgbotapi.NewMessage(update.Message.Chat.ID, response)
-					bot.Send(reply)
-				}
+			if aiCommandRe.MatchString(update.Message.Text) {
+				go handleAIMessage(ctx, bot, update.Message, msgType)
 				continue
 			}
 
@@ -125,7 +168,8 @@ func StartBot(token string) {
 				args := update.Message.CommandArguments()
 				log.Printf("[COMMAND] /%s %s", command, args)
 
-				if command == "run" && args != "" {
+				switch {
+				case command == "run" && args != "":
 					out, err := runShellCommand(args)
 					resp := ""
 					if err != nil {
@@ -135,28 +179,259 @@ func StartBot(token string) {
 					}
 					reply := tgbotapi.NewMessage(update.Message.Chat.ID, resp)
 					bot.Send(reply)
-					continue
-				}
-
-				if command == "status" {
-					reply := tgbotapi.NewMessage(update.Message.Chat.ID, "🤖 Bot is running and tracking conversations.")
+				case command == "status":
+					reply := tgbotapi.NewMessage(update.Message.Chat.ID, "🤖 Bot is running and tracking conversations.\n\n"+FormatAIStatus()+"\n"+FormatAIQueueStatus())
+					bot.Send(reply)
+				case command == "stop":
+					handleStopCommand(bot, update.Message, args)
+				case command == "recall":
+					go handleRecallCommand(ctx, bot, update.Message, args)
+				case command == "ask":
+					go handleAskCommand(ctx, bot, update.Message, args)
+				case command == "stats":
+					handleStatsCommand(bot, update.Message, args)
+				case command == "forgetme":
+					handleForgetMeCommand(bot, update.Message, args)
+				case command == "autoreply":
+					handleAutoReplyCommand(bot, update.Message, args)
+				case command == "mode":
+					handleModeCommand(bot, update.Message, args)
+				case command == "settings":
+					handleSettingsCommand(bot, update.Message, args)
+				case command == "prompt":
+					handlePromptCommand(bot, update.Message, args)
+				case command == "tldr":
+					go handleTldrCommand(ctx, bot, update.Message, args)
+				case command == "digest":
+					handleDigestCommand(ctx, bot, update.Message, args)
+				case command == "notes":
+					handleNotesCommand(ctx, bot, update.Message, args)
+				case command == "actions":
+					handleActionsCommand(bot, update.Message, args)
+				case command == "doc":
+					handleDocCommand(bot, update.Message, args)
+				case command == "cache":
+					handleCacheCommand(bot, update.Message, args)
+				case command == "models":
+					handleModelsCommand(bot, update.Message)
+				case command == "model":
+					handleModelCommand(bot, update.Message, args)
+				default:
+					reply := tgbotapi.NewMessage(update.Message.Chat.ID,
+						fmt.Sprintf("⚡ Command processed: /%s", command))
 					bot.Send(reply)
-					continue
 				}
+			} else if shouldRespond(bot.Self, update.Message, settings) {
+				go sendSmartReply(ctx, bot, update.Message, msgType, rowID)
+			}
+		}
+	}
+}
 
-				reply := tgbotapi.NewMessage(update.Message.Chat.ID,
-					fmt.Sprintf("⚡ Command processed: /%s", command))
-				bot.Send(reply)
-			} else if shouldRespond(update.Message.Text, update.Message.Chat.ID) {
-				response, err := getSmartReply(update.Message.Text, msgType)
-				if err != nil {
-					log.Printf("Error getting smart reply: %v", err)
-					continue
-				}
-				reply := tgbotapi.NewMessage(update.Message.Chat.ID, response)
-				bot.Send(reply)
+// handleAIMessage answers a message containing /ai, about the chat's current
+// document if there is one. It runs in its own goroutine and may wait in the
+// AI queue, telling the sender their position.
+func handleAIMessage(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, msgType string) {
+	settings := ResolveAISettings(m.Chat.ID, m.From.ID)
+	if settings.SystemPrompt == "" {
+		settings.SystemPrompt = "Reply in one concise sentence. Use two only if absolutely necessary, and use as few words as possible."
+	}
+	prompt, key := buildAIPrompt(m, msgType)
+	if doc := followUpDocument(m); doc != nil {
+		prompt = renderMessagePrompt("document_question", m, msgType, stripBotAddress(bot.Self, m.Text), doc)
+		key = nil
+	}
+	task := aiTaskFor(m, "ai")
+	task.OnWait = queueNotifier(bot, m)
+	response, err := settingsAIChat(WithAITask(ctx, task), settings, prompt, key)
+	if text, ok := aiLimitText(err); ok {
+		if text != "" {
+			bot.Send(tgbotapi.NewMessage(m.Chat.ID, text))
+		}
+		return
+	}
+	if errors.Is(err, ErrAIUnavailable) {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, DegradedMessage))
+	} else if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "[AI error] "+err.Error()))
+	} else {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, response))
+	}
+}
+
+// sendSmartReply sends getSmartReply's answer to an unprompted message. It
+// runs in its own goroutine so the update loop never waits for the AI.
+func sendSmartReply(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, msgType string, rowID int64) {
+	response, err := getSmartReply(ctx, m, msgType)
+	if err != nil {
+		log.Printf("Error getting smart reply: %v", err)
+		return
+	}
+	if response == "" {
+		return
+	}
+	reply := tgbotapi.NewMessage(m.Chat.ID, response)
+	if rowID != 0 {
+		// Let admins correct the detected label to train the local classifier.
+		reply.ReplyMarkup = labelKeyboard(rowID, msgType)
+	}
+	bot.Send(reply)
+	markSmartReply(m.Chat.ID, time.Now())
+}
+
+// handleRecallCommand answers /recall <question> with the most similar past
+// messages from the same chat. It runs in its own goroutine and may wait in
+// the AI queue to embed the question.
+func handleRecallCommand(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, question string) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "Usage: /recall <question>"))
+		return
+	}
+	task := aiTaskFor(m, "recall")
+	task.OnWait = queueNotifier(bot, m)
+	results, err := SearchSimilarMessages(WithAITask(ctx, task), m.Chat.ID, question, 5)
+	if text, ok := aiLimitText(err); ok {
+		if text != "" {
+			bot.Send(tgbotapi.NewMessage(m.Chat.ID, text))
+		}
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "[Recall error] "+err.Error()))
+		return
+	}
+	reply := tgbotapi.NewMessage(m.Chat.ID, "🔎 "+FormatRecallResults(results))
+	reply.ReplyToMessageID = m.MessageID
+	reply.DisableWebPagePreview = true
+	bot.Send(reply)
+}
+
+// handleAskCommand answers /ask <question> from the indexed knowledge sources.
+// It runs in its own goroutine and may wait in the AI queue.
+func handleAskCommand(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, question string) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "Usage: /ask <question about our code or docs>"))
+		return
+	}
+	bot.Send(tgbotapi.NewChatAction(m.Chat.ID, tgbotapi.ChatTyping))
+	model := ResolveAISettings(m.Chat.ID, m.From.ID).Model
+	task := aiTaskFor(m, "ask")
+	task.OnWait = queueNotifier(bot, m)
+	answer, err := AnswerFromKnowledge(WithAITask(ctx, task), question, model)
+	if text, ok := aiLimitText(err); ok {
+		if text != "" {
+			bot.Send(tgbotapi.NewMessage(m.Chat.ID, text))
+		}
+		return
+	}
+	if errors.Is(err, ErrAIUnavailable) {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, DegradedMessage))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "[Ask error] "+err.Error()))
+		return
+	}
+	reply := tgbotapi.NewMessage(m.Chat.ID, answer)
+	reply.ReplyToMessageID = m.MessageID
+	bot.Send(reply)
+}
+
+// handleStatsCommand answers /stats [period] with activity for the current
+// chat, and /stats ai [period] with its AI usage.
+func handleStatsCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, period string) {
+	if sub, rest := splitFirstWord(period); strings.EqualFold(sub, "ai") {
+		handleAIStatsCommand(bot, m, rest)
+		return
+	}
+	since, label, err := ParseStatsPeriod(period)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, err.Error()))
+		return
+	}
+	stats, err := ComputeChatStats(m.Chat.ID, since, label)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "[Stats error] "+err.Error()))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(m.Chat.ID, FormatChatStats(stats)))
+}
+
+// handleAIStatsCommand answers /stats ai [period] with the chat's AI usage
+// and today's quota use.
+func handleAIStatsCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, period string) {
+	since, label, err := ParseStatsPeriod(period)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, err.Error()))
+		return
+	}
+	report, err := ComputeAIUsage(m.Chat.ID, since, label)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "[Stats error] "+err.Error()))
+		return
+	}
+	text := FormatAIUsage(report)
+	if quota := FormatAIQuotaUsage(m.Chat.ID, m.From.ID); quota != "" {
+		text += "\n\n" + quota
+	}
+	sendLongMessage(bot, m.Chat.ID, text)
+}
+
+// handleForgetMeCommand erases the sender's stored data, everywhere by default
+// or only in the current chat with "/forgetme here".
+func handleForgetMeCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	var chatID int64
+	scope := "all chats"
+	if strings.TrimSpace(strings.ToLower(args)) == "here" {
+		chatID = m.Chat.ID
+		scope = "this chat"
+	}
+	n, err := ForgetUser(m.From.ID, chatID, fmt.Sprintf("user:%d", m.From.ID))
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "[Forget error] "+err.Error()))
+		return
+	}
+	reply := tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("🗑 Deleted %d stored messages and derived data for you in %s.", n, scope))
+	reply.ReplyToMessageID = m.MessageID
+	bot.Send(reply)
+}
+
+// handleCallbackQuery dispatches inline-button presses.
+func handleCallbackQuery(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
+	switch {
+	case strings.HasPrefix(cq.Data, labelCallbackPrefix):
+		handleLabelCallback(bot, cq)
+	case strings.HasPrefix(cq.Data, settingsCallbackPrefix):
+		handleSettingsCallback(bot, cq)
+	case strings.HasPrefix(cq.Data, modelCallbackPrefix):
+		handleModelCallback(bot, cq)
+	case strings.HasPrefix(cq.Data, actionCallbackPrefix):
+		handleActionCallback(bot, cq)
+	default:
+		bot.Request(tgbotapi.NewCallback(cq.ID, ""))
+	}
+}
+
+// sendLongMessage sends text, split on line boundaries to stay under
+// Telegram's 4096 character message limit. A line too long to fit is cut
+// between runes, never inside one.
+func sendLongMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	const limit = 4000
+	for len(text) > limit {
+		cut := strings.LastIndex(text[:limit], "\n")
+		if cut <= 0 {
+			cut = limit
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
 			}
 		}
+		bot.Send(tgbotapi.NewMessage(chatID, text[:cut]))
+		text = strings.TrimLeft(text[cut:], "\n")
+	}
+	if strings.TrimSpace(text) != "" {
+		bot.Send(tgbotapi.NewMessage(chatID, text))
 	}
 }
 
@@ -170,121 +445,86 @@ func runShellCommand(cmdline string) (string, error) {
 	return string(out), err
 }
 
-func detectMessageType(text string) string {
-	text = strings.ToLower(text)
-	switch {
-	case strings.Contains(text, "issue"), strings.Contains(text, "bug"), strings.Contains(text, "error"), strings.Contains(text, "problem"):
-		return "issue"
-	case strings.Contains(text, "feature"), strings.Contains(text, "enhancement"), strings.Contains(text, "request"), strings.Contains(text, "add"):
-		return "feature_request"
-	case strings.Contains(text, "question"), strings.Contains(text, "how"), strings.Contains(text, "what"), strings.Contains(text, "?"):
-		return "question"
-	default:
-		return "message"
-	}
-}
-
-// shouldRespond returns true if the message should trigger a bot reply.
-func shouldRespond(text string, chatID int64) bool {
+// shouldRespond returns true if a non-command message should trigger a bot
+// reply, given the chat's engagement mode, quiet hours and cooldown.
+func shouldRespond(self tgbotapi.User, m *tgbotapi.Message, settings ChatSettings) bool {
+	text, chatID := m.Text, m.Chat.ID
 	lower := strings.ToLower(text)
-	// Detect mention (case-insensitive, multiple forms)
-	botMentioned := strings.Contains(lower, "@ideabglobe_bot") || strings.Contains(lower, "@ideabglobe")
-	// Detect /command anywhere in the message (not just at the start, and not if followed by a space)
+	// DMs, @mentions and replies to the bot's own messages
+	addressed := isAddressedToBot(self, m)
+	switch settings.Mode {
+	case ModeSilent, ModeCommandsOnly:
+		return false
+	case ModeMentionOnly:
+		return addressed
+	}
+	if !addressed && !allowUnsolicitedReply(settings, time.Now()) {
+		return false
+	}
+	// A /command anywhere in the message, as Telegram marks them; paths and
+	// URLs such as https://example.com/path are not commands.
 	isCommand := false
-	// Regex: match / followed by at least one word character, not / followed by a space
-	commandRe := regexp.MustCompile(`/[a-zA-Z0-9_]+`)
-	if commandRe.MatchString(lower) {
-		isCommand = true
+	for _, e := range m.Entities {
+		if e.IsCommand() {
+			isCommand = true
+			break
+		}
 	}
-	// Detect direct message
-	isDM := chatID > 0
 	// Detect question
 	isQuestion := strings.Contains(lower, "?")
-	// Detect keywords from auto-reply categories
-	if matchAutoReplyCategory(lower) != "" {
+	// Detect keywords and patterns from auto.json rules
+	if rule, _ := MatchAutoReply(text, chatID); rule != nil {
 		return true
 	}
-	return botMentioned || isDM || isQuestion || isCommand
-}
-
-// matchAutoReplyCategory returns the category if a keyword from auto.json is found in the text, else "".
-func matchAutoReplyCategory(text string) string {
-	replies := loadAutoReplies()
-	for _, ar := range replies {
-		if ar.Category == "greeting" && (strings.Contains(text, "hello") || strings.Contains(text, "hi") || strings.Contains(text, "hey")) {
-			return "greeting"
-		}
-		if ar.Category == "issue" && (strings.Contains(text, "issue") || strings.Contains(text, "bug") || strings.Contains(text, "problem") || strings.Contains(text, "error")) {
-			return "issue"
-		}
-		if ar.Category == "feature" && (strings.Contains(text, "feature") || strings.Contains(text, "enhancement") || strings.Contains(text, "request")) {
-			return "feature"
-		}
-		if ar.Category == "question" && (strings.Contains(text, "question") || strings.Contains(text, "how") || strings.Contains(text, "what") || strings.Contains(text, "why") || strings.Contains(text, "?")) {
-			return "question"
-		}
-		if ar.Category == "code" && (strings.Contains(text, "code") || strings.Contains(text, "review") || strings.Contains(text, "debug") || strings.Contains(text, "implementation")) {
-			return "code"
-		}
-	}
-	return ""
+	return addressed || isQuestion || isCommand
 }
 
-// loadAutoReplies loads auto.json from the app directory.
-func loadAutoReplies() []AutoReply {
-	path := GetAppDir() + "/auto.json"
-	f, err := os.Open(path)
-	if err != nil {
-		return nil
+// getSmartReply answers with AI when available and otherwise with a canned
+// auto.json reply. It never waits in the AI queue: a busy AI also gets the
+// canned reply. An empty reply means the bot should stay quiet, e.g. because
+// the matching rule is cooling down in this chat or the user sent /stop.
+func getSmartReply(ctx context.Context, m *tgbotapi.Message, msgType string) (string, error) {
+	chatID := m.Chat.ID
+	settings := ResolveAISettings(chatID, m.From.ID)
+	task := aiTaskFor(m, "auto")
+	task.NoWait = true
+	prompt, key := buildAIPrompt(m, msgType)
+	reply, err := settingsAIChat(WithAITask(ctx, task), settings, prompt, key)
+	if err == nil {
+		return reply, nil
 	}
-	defer f.Close()
-	var replies []AutoReply
-	if err := json.NewDecoder(f).Decode(&replies); err != nil {
-		return nil
+	if errors.Is(err, context.Canceled) {
+		return "", nil
 	}
-	return replies
-}
-
-func getSmartReply(text string, msgType string) (string, error) {
-	if reply, err := OllamaChat(buildAIPrompt(text, msgType)); err == nil {
+	data := autoReplyData(m)
+	if rule, keyword := MatchAutoReply(m.Text, chatID); rule != nil {
+		data.Keyword = keyword
+		reply, _ := RenderAutoReply(rule, chatID, data)
 		return reply, nil
 	}
-	return getAutoReply(msgType), nil
-}
-
-func buildAIPrompt(text string, msgType string) string {
-	context := fmt.Sprintf("You are a software engineering assistant bot in a Telegram group. Message type: %s. Be concise and helpful.", msgType)
-	return fmt.Sprintf("%s\n\nUser message: %s", context, text)
+	reply, _ = getAutoReply(msgType, chatID, data)
+	return reply, nil
 }
 
-func getAutoReply(category string) string {
-	autoReplies := map[string][]string{
-		"issue": {
-			"🐛 I see you've mentioned an issue. Can you provide more details?",
-			"📝 Please create a detailed issue report with steps to reproduce.",
-			"🔍 Let me help you troubleshoot this problem.",
-		},
-		"feature_request": {
-			"💡 Interesting feature idea! Let's discuss the requirements.",
-			"🚀 That sounds like a useful enhancement. Can you elaborate?",
-			"📋 I'll help you draft a proper feature request.",
-		},
-		"question": {
-			"🤔 Good question! Let me think about this...",
-			"📚 I can help you with that. Here's what I know:",
-			"💭 Interesting question. Let me research that for you.",
-		},
-		"default": {
-			"👍 Noted! I'm tracking this conversation.",
-			"📊 I'm here to help with software engineering tasks.",
-			"🤖 How can I assist with your development work?",
-		},
-	}
-	replies, exists := autoReplies[category]
-	if !exists {
-		replies = autoReplies["default"]
-	}
-	return replies[len(replies)%3]
+func autoReplyData(m *tgbotapi.Message) AutoReplyData {
+	now := time.Now()
+	data := AutoReplyData{
+		ChatID:    m.Chat.ID,
+		ChatTitle: m.Chat.Title,
+		Time:      now.Format("15:04"),
+		Date:      now.Format("2006-01-02"),
+	}
+	if m.From != nil {
+		data.Username = m.From.UserName
+		data.FirstName = m.From.FirstName
+		if data.FirstName == "" {
+			data.FirstName = m.From.UserName
+		}
+	}
+	if data.ChatTitle == "" {
+		data.ChatTitle = "this chat"
+	}
+	return data
 }
 
 // RunDefaultBot starts the default bot from config
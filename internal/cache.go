@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultCacheTTL        = 24 * time.Hour
+	defaultCacheMaxEntries = 1000
+)
+
+func initCacheTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS ai_cache (
+			key TEXT PRIMARY KEY,
+			chat_id INTEGER,
+			user_id INTEGER,
+			model TEXT,
+			prompt TEXT,
+			response TEXT,
+			hits INTEGER DEFAULT 0,
+			created DATETIME,
+			last_hit DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	return ensureColumn("chat_settings", "cache_disabled", "BOOLEAN DEFAULT 0")
+}
+
+// CacheKey identifies an AI answer that may be reused. Only Question and
+// Context are compared (besides the request's model, system prompt, format and
+// options), so callers leave out anything that changes between askers, such
+// as the sender's name or recent chat history.
+type CacheKey struct {
+	Question string   // normalized with NormalizePrompt
+	Context  []string // e.g. the prompt name or the retrieved sources
+	PerChat  bool     // only reuse the answer in the chat that asked
+}
+
+// NormalizePrompt lowercases a question, drops commands and @mentions and
+// collapses whitespace and trailing punctuation, so trivially different
+// phrasings share a cache entry.
+func NormalizePrompt(s string) string {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(s)) {
+		if strings.HasPrefix(w, "/") || strings.HasPrefix(w, "@") {
+			continue
+		}
+		words = append(words, w)
+	}
+	return strings.TrimRightFunc(strings.Join(words, " "), func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+}
+
+func cacheSettings() (enabled bool, ttl time.Duration, maxEntries int) {
+	cfg := currentConfig().AICache
+	ttl, maxEntries = time.Duration(cfg.TTLMinutes)*time.Minute, cfg.MaxEntries
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	return !cfg.Disabled, ttl, maxEntries
+}
+
+// ChatCacheEnabled reports whether AI answers may be cached and reused in
+// chatID.
+func ChatCacheEnabled(chatID int64) bool {
+	var disabled sql.NullBool
+	if DB == nil || DB.QueryRow(`SELECT cache_disabled FROM chat_settings WHERE chat_id = ?`, chatID).Scan(&disabled) != nil {
+		return true
+	}
+	return !disabled.Bool
+}
+
+// SetChatCache opts chatID in to or out of the response cache.
+func SetChatCache(chatID int64, enabled bool) error {
+	_, err := DB.Exec(`INSERT INTO chat_settings (chat_id, cache_disabled, updated) VALUES (?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET cache_disabled = excluded.cache_disabled, updated = excluded.updated`,
+		chatID, !enabled, time.Now().UTC())
+	return err
+}
+
+func (k CacheKey) hash(r OllamaRequest, chatID int64) string {
+	if !k.PerChat {
+		chatID = 0
+	}
+	b, _ := json.Marshal([]interface{}{r.Model, r.System, r.Format, r.Options, NormalizePrompt(k.Question), k.Context, chatID})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// CachedGenerate answers r from the response cache when an unexpired answer
+// for the same key exists, and otherwise calls GenerateWithFallback and
+// caches a successful answer. cached reports a cache hit. Requests with
+// images, and chats that opted out, bypass the cache.
+func CachedGenerate(ctx context.Context, r OllamaRequest, k CacheKey) (resp *OllamaResponse, cached bool, err error) {
+	task, _ := ctx.Value(aiTaskKey{}).(AITask)
+	enabled, ttl, maxEntries := cacheSettings()
+	if !enabled || len(r.Images) > 0 || NormalizePrompt(k.Question) == "" || !ChatCacheEnabled(task.ChatID) {
+		resp, err = GenerateWithFallback(ctx, r)
+		return resp, false, err
+	}
+	key := k.hash(r, task.ChatID)
+	now := time.Now().UTC()
+	var model, response string
+	err = DB.QueryRow(`SELECT model, response FROM ai_cache WHERE key = ? AND created >= ?`, key, now.Add(-ttl)).Scan(&model, &response)
+	if err == nil {
+		if _, err := DB.Exec(`UPDATE ai_cache SET hits = hits + 1, last_hit = ? WHERE key = ?`, now, key); err != nil {
+			log.Printf("Error updating AI cache: %v", err)
+		}
+		return &OllamaResponse{Model: model, Response: response, Done: true}, true, nil
+	}
+	resp, err = GenerateWithFallback(ctx, r)
+	if err != nil || strings.TrimSpace(resp.Response) == "" {
+		return resp, false, err
+	}
+	chatID := task.ChatID
+	if !k.PerChat {
+		chatID = 0
+	}
+	_, err = DB.Exec(`INSERT INTO ai_cache (key, chat_id, user_id, model, prompt, response, hits, created, last_hit)
+		VALUES (?, ?, ?, ?, ?, ?, 0, ?, NULL)
+		ON CONFLICT(key) DO UPDATE SET model = excluded.model, response = excluded.response, hits = 0,
+			created = excluded.created, last_hit = NULL, user_id = excluded.user_id`,
+		key, chatID, task.UserID, resp.Model, NormalizePrompt(k.Question), resp.Response, now)
+	if err != nil {
+		log.Printf("Error storing AI cache entry: %v", err)
+	} else if err := pruneCache(ttl, maxEntries); err != nil {
+		log.Printf("Error pruning AI cache: %v", err)
+	}
+	return resp, false, nil
+}
+
+// pruneCache drops expired entries and then the least recently used ones
+// beyond maxEntries.
+func pruneCache(ttl time.Duration, maxEntries int) error {
+	if _, err := DB.Exec(`DELETE FROM ai_cache WHERE created < ?`, time.Now().UTC().Add(-ttl)); err != nil {
+		return err
+	}
+	_, err := DB.Exec(`DELETE FROM ai_cache WHERE key IN (
+		SELECT key FROM ai_cache ORDER BY COALESCE(last_hit, created) DESC LIMIT -1 OFFSET ?)`, maxEntries)
+	return err
+}
+
+// CacheEntry is one cached answer, as listed by ComputeCacheStats.
+type CacheEntry struct {
+	ChatID  int64     `json:"chat_id"`
+	Model   string    `json:"model"`
+	Prompt  string    `json:"prompt"`
+	Hits    int       `json:"hits"`
+	Created time.Time `json:"created"`
+}
+
+// CacheStats summarises the response cache.
+type CacheStats struct {
+	Entries    int          `json:"entries"`
+	Expired    int          `json:"expired"`
+	Bytes      int64        `json:"bytes"`
+	Hits       int64        `json:"hits"`
+	TTL        string       `json:"ttl"`
+	MaxEntries int          `json:"max_entries"`
+	Enabled    bool         `json:"enabled"`
+	Top        []CacheEntry `json:"top"`
+}
+
+// HitRate is the share of lookups answered from the cache: every entry was
+// created by one miss, every reuse is a hit.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+int64(s.Entries))
+}
+
+// ComputeCacheStats summarises the cache for chatID (0 for the whole cache)
+// with its top most reused entries.
+func ComputeCacheStats(chatID int64, top int) (*CacheStats, error) {
+	enabled, ttl, maxEntries := cacheSettings()
+	s := &CacheStats{TTL: ttl.String(), MaxEntries: maxEntries, Enabled: enabled}
+	where, args := "1=1", []interface{}{}
+	if chatID != 0 {
+		where, args = "chat_id = ?", append(args, chatID)
+	}
+	err := DB.QueryRow(`SELECT COUNT(*), COALESCE(SUM(CASE WHEN created < ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(LENGTH(response) + LENGTH(prompt)), 0), COALESCE(SUM(hits), 0) FROM ai_cache WHERE `+where,
+		append([]interface{}{time.Now().UTC().Add(-ttl)}, args...)...).Scan(&s.Entries, &s.Expired, &s.Bytes, &s.Hits)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := DB.Query(`SELECT chat_id, model, prompt, hits, created FROM ai_cache WHERE `+where+`
+		ORDER BY hits DESC, created DESC LIMIT ?`, append(args, top)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e CacheEntry
+		if err := rows.Scan(&e.ChatID, &e.Model, &e.Prompt, &e.Hits, &e.Created); err != nil {
+			return nil, err
+		}
+		s.Top = append(s.Top, e)
+	}
+	return s, rows.Err()
+}
+
+// ClearCache deletes cached answers: those of chatID (0 for every chat), or
+// with expiredOnly just the expired ones. It returns how many were deleted.
+func ClearCache(chatID int64, expiredOnly bool) (int64, error) {
+	where, args := "1=1", []interface{}{}
+	if chatID != 0 {
+		where, args = "chat_id = ?", append(args, chatID)
+	}
+	if expiredOnly {
+		_, ttl, _ := cacheSettings()
+		where += " AND created < ?"
+		args = append(args, time.Now().UTC().Add(-ttl))
+	}
+	res, err := DB.Exec(`DELETE FROM ai_cache WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
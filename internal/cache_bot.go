@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const cacheUsage = `Usage:
+/cache — show whether AI answers are reused in this chat
+/cache on|off — allow or stop reusing AI answers here (chat admins)
+/cache clear — forget this chat's cached answers (chat admins)`
+
+// handleCacheCommand shows and changes a chat's response cache opt-out.
+func handleCacheCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	sub := strings.ToLower(strings.TrimSpace(args))
+	if sub == "" {
+		reply(describeChatCache(m.Chat.ID) + "\n\n" + cacheUsage)
+		return
+	}
+	if !canManageChat(bot, m.Chat, m.From.ID) {
+		reply("⛔ Only chat admins can change the response cache.")
+		return
+	}
+	switch sub {
+	case "on", "off":
+		if err := SetChatCache(m.Chat.ID, sub == "on"); err != nil {
+			reply("[Cache error] " + err.Error())
+			return
+		}
+		log.Printf("[CACHE] chat %d: cache %s by %d", m.Chat.ID, sub, m.From.ID)
+		reply("✅ " + describeChatCache(m.Chat.ID))
+	case "clear":
+		n, err := ClearCache(m.Chat.ID, false)
+		if err != nil {
+			reply("[Cache error] " + err.Error())
+			return
+		}
+		log.Printf("[CACHE] chat %d: %d entries cleared by %d", m.Chat.ID, n, m.From.ID)
+		reply(fmt.Sprintf("🧹 Cleared %d cached answers.", n))
+	default:
+		reply(cacheUsage)
+	}
+}
+
+func describeChatCache(chatID int64) string {
+	enabled, _, _ := cacheSettings()
+	switch {
+	case !enabled:
+		return "The response cache is turned off for this bot."
+	case !ChatCacheEnabled(chatID):
+		return "Repeated questions here always get a fresh AI answer."
+	}
+	text := "Repeated questions here reuse earlier AI answers."
+	if s, err := ComputeCacheStats(chatID, 0); err == nil && s.Entries > 0 {
+		text += fmt.Sprintf(" %d answers cached, reused %d times.", s.Entries, s.Hits)
+	}
+	return text
+}
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChatMessage is one turn of an Ollama /api/chat conversation.
+type ChatMessage struct {
+	Role    string   `json:"role"` // system, user or assistant
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+// ChatRequest is the body of an Ollama /api/chat call.
+type ChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ChatMessage          `json:"messages"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Stream   bool                   `json:"stream"`
+}
+
+// ChatResponse is a /api/chat response, or one chunk of a streamed response.
+type ChatResponse struct {
+	Model           string      `json:"model"`
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	TotalDuration   int64       `json:"total_duration"`
+	Error           string      `json:"error"`
+}
+
+// OllamaChatStream sends a chat request and calls onToken with each streamed
+// piece of the reply. It returns the full reply with the final chunk's counts.
+func OllamaChatStream(ctx context.Context, r ChatRequest, onToken func(string)) (*ChatResponse, error) {
+	r.Stream = true
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", ollamaBaseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var full strings.Builder
+	var last ChatResponse
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk ChatResponse
+		if err := dec.Decode(&chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("ollama chat response: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama: %s", chunk.Error)
+		}
+		full.WriteString(chunk.Message.Content)
+		if onToken != nil && chunk.Message.Content != "" {
+			onToken(chunk.Message.Content)
+		}
+		last = chunk
+		if chunk.Done {
+			break
+		}
+	}
+	last.Message = ChatMessage{Role: "assistant", Content: full.String()}
+	return &last, nil
+}
+
+func initChatSessionTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS chat_sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE,
+			model TEXT,
+			system TEXT,
+			created DATETIME,
+			updated DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	// Unnamed sessions are stored with a NULL name; older versions used '',
+	// which the UNIQUE constraint allows only once.
+	if _, err := DB.Exec(`UPDATE chat_sessions SET name = NULL WHERE name = ''`); err != nil {
+		return err
+	}
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS chat_session_messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id INTEGER,
+			role TEXT,
+			content TEXT,
+			created DATETIME
+		)
+	`)
+	return err
+}
+
+// ChatSession is a saved `ai chat` conversation.
+type ChatSession struct {
+	ID       int64
+	Name     string
+	Model    string
+	System   string
+	Created  time.Time
+	Updated  time.Time
+	Messages []ChatMessage
+}
+
+// SaveChatSession creates the session, or updates its name, model and system
+// prompt, and appends any messages not yet stored (those past stored).
+func SaveChatSession(s *ChatSession, stored int) error {
+	now := time.Now().UTC()
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	name := sql.NullString{String: s.Name, Valid: s.Name != ""}
+	if s.ID == 0 {
+		res, err := tx.Exec(`INSERT INTO chat_sessions (name, model, system, created, updated) VALUES (?, ?, ?, ?, ?)`,
+			name, s.Model, s.System, now, now)
+		if err != nil {
+			return err
+		}
+		if s.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	} else if _, err := tx.Exec(`UPDATE chat_sessions SET name = ?, model = ?, system = ?, updated = ? WHERE id = ?`,
+		name, s.Model, s.System, now, s.ID); err != nil {
+		return err
+	}
+	for _, m := range s.Messages[min(stored, len(s.Messages)):] {
+		if _, err := tx.Exec(`INSERT INTO chat_session_messages (session_id, role, content, created) VALUES (?, ?, ?, ?)`,
+			s.ID, m.Role, m.Content, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadChatSession loads a session by ID or name, with its messages.
+func LoadChatSession(ref string) (*ChatSession, error) {
+	query := `SELECT id, COALESCE(name, ''), COALESCE(model, ''), COALESCE(system, ''), created, updated FROM chat_sessions WHERE name = ?`
+	var arg interface{} = ref
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		query = `SELECT id, COALESCE(name, ''), COALESCE(model, ''), COALESCE(system, ''), created, updated FROM chat_sessions WHERE id = ?`
+		arg = id
+	}
+	var s ChatSession
+	err := DB.QueryRow(query, arg).Scan(&s.ID, &s.Name, &s.Model, &s.System, &s.Created, &s.Updated)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no chat session %q", ref)
+	} else if err != nil {
+		return nil, err
+	}
+	rows, err := DB.Query(`SELECT role, content FROM chat_session_messages WHERE session_id = ? ORDER BY id`, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m ChatMessage
+		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+			return nil, err
+		}
+		s.Messages = append(s.Messages, m)
+	}
+	return &s, rows.Err()
+}
+
+// ListChatSessions returns saved sessions, most recently updated first, with
+// the number of messages in each.
+func ListChatSessions() ([]ChatSession, []int, error) {
+	rows, err := DB.Query(`SELECT s.id, COALESCE(s.name, ''), COALESCE(s.model, ''), s.created, s.updated,
+		(SELECT COUNT(*) FROM chat_session_messages m WHERE m.session_id = s.id)
+		FROM chat_sessions s ORDER BY s.updated DESC`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var sessions []ChatSession
+	var counts []int
+	for rows.Next() {
+		var s ChatSession
+		var n int
+		if err := rows.Scan(&s.ID, &s.Name, &s.Model, &s.Created, &s.Updated, &n); err != nil {
+			return nil, nil, err
+		}
+		sessions = append(sessions, s)
+		counts = append(counts, n)
+	}
+	return sessions, counts, rows.Err()
+}
+
+// DeleteChatSession removes a saved session and its messages.
+func DeleteChatSession(id int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM chat_session_messages WHERE session_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_sessions WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
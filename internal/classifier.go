@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message labels shared by classification, auto-reply categories and stats.
+const (
+	LabelIssue          = "issue"
+	LabelFeatureRequest = "feature_request"
+	LabelQuestion       = "question"
+	LabelGreeting       = "greeting"
+	LabelCode           = "code"
+	LabelMessage        = "message" // anything else
+)
+
+// Labels is the taxonomy the classifiers choose from.
+var Labels = []string{LabelIssue, LabelFeatureRequest, LabelQuestion, LabelGreeting, LabelCode, LabelMessage}
+
+var labelAliases = map[string]string{
+	"feature":         LabelFeatureRequest,
+	"feature-request": LabelFeatureRequest,
+	"enhancement":     LabelFeatureRequest,
+	"bug":             LabelIssue,
+	"problem":         LabelIssue,
+	"greet":           LabelGreeting,
+	"other":           LabelMessage,
+}
+
+// NormalizeLabel maps aliases such as "feature" or "bug" onto the shared
+// taxonomy. Unknown labels (custom auto-reply categories) are returned as-is.
+func NormalizeLabel(label string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if alias, ok := labelAliases[label]; ok {
+		return alias
+	}
+	if label == "" {
+		return LabelMessage
+	}
+	return label
+}
+
+// Classification is the label chosen for a message and how sure the classifier is.
+type Classification struct {
+	Label      string
+	Confidence float64 // 0..1
+	Source     string  // which classifier produced it: rules, ai, local, admin
+}
+
+// Classifier assigns a label to a message text.
+type Classifier interface {
+	Classify(ctx context.Context, text string) (Classification, error)
+}
+
+// RulesClassifier labels messages with the category of the highest-priority
+// auto-reply rule that matches, without any AI call. ChatID selects the
+// chat-scoped rules that apply; 0 uses global rules only.
+type RulesClassifier struct {
+	ChatID int64
+}
+
+func (c RulesClassifier) Classify(ctx context.Context, text string) (Classification, error) {
+	rule, _ := matchAutoReplyRules(loadAutoReplies(), text, c.ChatID)
+	if rule == nil {
+		return Classification{Label: LabelMessage, Confidence: 0.3, Source: "rules"}, nil
+	}
+	confidence := 0.7
+	if len(rule.Patterns) > 0 {
+		confidence = 0.75
+	}
+	return Classification{Label: NormalizeLabel(rule.Category), Confidence: confidence, Source: "rules"}, nil
+}
+
+// AIClassifier asks an Ollama model, in JSON mode, for a label and confidence.
+type AIClassifier struct {
+	Model string
+}
+
+func (c AIClassifier) Classify(ctx context.Context, text string) (Classification, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	prompt := fmt.Sprintf(`Classify the following chat message from a software engineering team.
+Choose exactly one label from: %s.
+- issue: reports a bug, error, crash or something broken
+- feature_request: proposes new functionality or an enhancement
+- question: asks for help or information
+- greeting: says hello or goodbye
+- code: shares or discusses code, reviews or implementation details
+- message: anything else
+Respond with JSON only: {"label": "<label>", "confidence": <number between 0 and 1>}
+
+Message: %s`, strings.Join(Labels, ", "), text)
+	resp, err := GenerateWithFallback(ctx, OllamaRequest{Model: c.Model, Prompt: prompt, Format: "json", Options: map[string]interface{}{"temperature": 0}})
+	if err != nil {
+		return Classification{}, err
+	}
+	var out struct {
+		Label      string  `json:"label"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(resp.Response), &out); err != nil {
+		return Classification{}, fmt.Errorf("classifier response: %s", resp.Response)
+	}
+	label := NormalizeLabel(out.Label)
+	if !isKnownLabel(label) {
+		return Classification{Label: LabelMessage, Confidence: 0.2, Source: "ai"}, nil
+	}
+	return Classification{Label: label, Confidence: clamp01(out.Confidence), Source: "ai"}, nil
+}
+
+// ChainClassifier tries classifiers in order and returns the first result at
+// or above Threshold. If none is confident enough, the most confident result
+// wins; classifier errors are skipped.
+type ChainClassifier struct {
+	Classifiers []Classifier
+	Threshold   float64
+}
+
+func (c ChainClassifier) Classify(ctx context.Context, text string) (Classification, error) {
+	var best Classification
+	var lastErr error
+	found := false
+	for _, cl := range c.Classifiers {
+		res, err := cl.Classify(ctx, text)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if res.Confidence >= c.Threshold {
+			return res, nil
+		}
+		if !found || res.Confidence > best.Confidence {
+			best, found = res, true
+		}
+	}
+	if !found {
+		return Classification{}, lastErr
+	}
+	return best, nil
+}
+
+// NewClassifier builds the classifier selected by Configs.Classifier:
+// "rules", "ai", "local" (the trained model, AI when it is not confident) or
+// "combined" (local model if trained, then rules, then AI).
+func NewClassifier(cfg *Configs) Classifier {
+	ai := AIClassifier{Model: cfg.AIModel()}
+	local := LoadLocalModel()
+	switch cfg.Classifier {
+	case "rules":
+		return RulesClassifier{}
+	case "ai":
+		return ChainClassifier{Classifiers: []Classifier{ai, RulesClassifier{}}, Threshold: 0}
+	case "local":
+		return ChainClassifier{Classifiers: []Classifier{LocalClassifier{Model: local}, ai, RulesClassifier{}}, Threshold: cfg.ClassifierThreshold}
+	default:
+		chain := []Classifier{RulesClassifier{}, ai}
+		if local != nil {
+			chain = append([]Classifier{LocalClassifier{Model: local}}, chain...)
+		}
+		return ChainClassifier{Classifiers: chain, Threshold: cfg.ClassifierThreshold}
+	}
+}
+
+// botClassifier labels incoming messages for the update loop. Only the rules
+// and the local model run there; when the configured classifier also uses the
+// AI, messages they are unsure of are relabelled in the background so the loop
+// never waits for Ollama.
+type botClassifier struct {
+	local     bool // consult the trained local model, if there is one
+	ai        Classifier
+	threshold float64
+}
+
+var (
+	messageClassifierOnce sync.Once
+	messageClassifier     *botClassifier
+)
+
+// currentBotClassifier returns the bot's classifier, built once from the
+// config.
+func currentBotClassifier() *botClassifier {
+	messageClassifierOnce.Do(func() {
+		cfg := currentConfig()
+		c := &botClassifier{threshold: cfg.ClassifierThreshold}
+		switch cfg.Classifier {
+		case "rules":
+		case "ai":
+			c.ai, c.threshold = AIClassifier{Model: cfg.AIModel()}, 1
+		default: // local, combined
+			c.local, c.ai = true, AIClassifier{Model: cfg.AIModel()}
+		}
+		messageClassifier = c
+	})
+	return messageClassifier
+}
+
+// classify runs the classifiers that need no AI call: the local model if one
+// is trained and confident, else the chat's rules.
+func (c *botClassifier) classify(ctx context.Context, chatID int64, text string) (Classification, error) {
+	chain := ChainClassifier{Classifiers: []Classifier{RulesClassifier{ChatID: chatID}}, Threshold: c.threshold}
+	if model := LoadLocalModel(); c.local && model != nil {
+		chain.Classifiers = append([]Classifier{LocalClassifier{Model: model}}, chain.Classifiers...)
+	}
+	return chain.Classify(ctx, text)
+}
+
+// classifyMessage labels text without calling the AI, falling back to
+// "message" if every classifier fails.
+func classifyMessage(chatID int64, text string) Classification {
+	if strings.TrimSpace(text) == "" {
+		return Classification{Label: LabelMessage, Confidence: 1, Source: "rules"}
+	}
+	res, err := currentBotClassifier().classify(context.Background(), chatID, text)
+	if err != nil {
+		return Classification{Label: LabelMessage, Confidence: 0, Source: "none"}
+	}
+	return res
+}
+
+// relabelMessage asks the AI classifier about a stored message that
+// classifyMessage labelled with low confidence, and stores its label if it is
+// more confident. Bot commands are never sent to the AI, and the call never
+// waits in the AI queue. It runs in its own goroutine.
+func relabelMessage(rowID, chatID, userID int64, text string, fast Classification) {
+	c := currentBotClassifier()
+	if c.ai == nil || rowID == 0 || fast.Confidence >= c.threshold ||
+		strings.TrimSpace(text) == "" || strings.HasPrefix(text, "/") {
+		return
+	}
+	ctx := WithAITask(context.Background(), AITask{ChatID: chatID, UserID: userID, Kind: "classify", NoWait: true})
+	res, err := c.ai.Classify(ctx, text)
+	if err != nil || res.Confidence <= fast.Confidence {
+		return
+	}
+	// An admin's correction made in the meantime wins.
+	if _, err := DB.Exec(`UPDATE messages SET type = ?, confidence = ?, label_source = ? WHERE id = ? AND label_source != 'admin'`,
+		res.Label, res.Confidence, res.Source, rowID); err != nil {
+		log.Printf("Error relabelling message %d: %v", rowID, err)
+	}
+}
+
+func isKnownLabel(label string) bool {
+	for _, l := range Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func clamp01(f float64) float64 {
+	switch {
+	case f < 0:
+		return 0
+	case f > 1:
+		return 1
+	}
+	return f
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// labelCallbackPrefix marks inline-button callbacks that correct a stored
+// message's label: "cls:<messages row id>:<label>".
+const labelCallbackPrefix = "cls:"
+
+// labelKeyboard offers one button per label so admins can correct the label
+// detected for message row id. The current label is ticked.
+func labelKeyboard(id int64, current string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, label := range Labels {
+		text := label
+		if label == current {
+			text = "✅ " + label
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("%s%d:%s", labelCallbackPrefix, id, label)))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleLabelCallback stores an admin's label correction and ticks the chosen
+// button on the reply.
+func handleLabelCallback(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
+	idArg, label, ok := strings.Cut(strings.TrimPrefix(cq.Data, labelCallbackPrefix), ":")
+	id, err := strconv.ParseInt(idArg, 10, 64)
+	if !ok || err != nil || cq.Message == nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Invalid label button"))
+		return
+	}
+	if !isChatAdmin(bot, cq.Message.Chat.ID, cq.From.ID) {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "⛔ Only admins can correct labels"))
+		return
+	}
+	if err := SetMessageLabel(id, label); err != nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "[Label error] "+err.Error()))
+		return
+	}
+	log.Printf("[LABEL] message %d labelled %s by %d", id, label, cq.From.ID)
+	bot.Request(tgbotapi.NewCallback(cq.ID, "Labelled as "+label))
+	bot.Request(tgbotapi.NewEditMessageReplyMarkup(cq.Message.Chat.ID, cq.Message.MessageID, labelKeyboard(id, label)))
+}
+
+// isChatAdmin reports whether userID is a bot admin (Configs.Admins) or an
+// administrator of the chat.
+func isChatAdmin(bot *tgbotapi.BotAPI, chatID, userID int64) bool {
+	if currentConfig().IsAdmin(userID) {
+		return true
+	}
+	if chatID > 0 {
+		return false
+	}
+	member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID}})
+	if err != nil {
+		return false
+	}
+	return member.IsAdministrator() || member.IsCreator()
+}
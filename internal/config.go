@@ -10,13 +10,131 @@ import (
 type BotConfig struct {
 	ID    string `json:"id"`
 	Token string `json:"token"`
+
+	// Optional bot-level AI defaults, overridden by /settings per chat or user.
+	AIModel  string `json:"ai_model,omitempty"`
+	AIPrompt string `json:"ai_prompt,omitempty"`
+}
+
+// KnowledgeSource is a local directory (a repository, a docs folder) whose
+// files are chunked and embedded so /ask can answer questions about it.
+type KnowledgeSource struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path"`
+	Include []string `json:"include,omitempty"` // file name globs, e.g. "*.go"; empty means common text files
+}
+
+// RetentionPolicy limits how long messages are kept. Zero values disable a limit.
+type RetentionPolicy struct {
+	MaxAgeDays  int `json:"max_age_days,omitempty"`
+	MaxMessages int `json:"max_messages,omitempty"`
+}
+
+// RetentionConfig holds the default policy and per-chat overrides keyed by chat ID.
+type RetentionConfig struct {
+	Default         RetentionPolicy           `json:"default"`
+	Chats           map[int64]RetentionPolicy `json:"chats,omitempty"`
+	IntervalMinutes int                       `json:"interval_minutes,omitempty"`
+}
+
+// PolicyFor returns the retention policy that applies to chatID.
+func (r RetentionConfig) PolicyFor(chatID int64) RetentionPolicy {
+	if p, ok := r.Chats[chatID]; ok {
+		return p
+	}
+	return r.Default
+}
+
+// AIBackend is one model to try for AI replies, optionally on another Ollama host.
+type AIBackend struct {
+	Model string `json:"model"`
+	URL   string `json:"url,omitempty"` // Ollama base URL; empty means the local Ollama
+}
+
+// CircuitBreakerConfig controls when a failing AI backend is skipped.
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold,omitempty"` // consecutive failures before the circuit opens (default 3)
+	CooldownSeconds  int `json:"cooldown_seconds,omitempty"`  // how long to skip a backend before probing it again (default 30)
+}
+
+// AIQueueConfig limits concurrent AI calls on the Ollama host.
+type AIQueueConfig struct {
+	Concurrency int `json:"concurrency,omitempty"` // AI calls running at once (default 1)
+	PerUser     int `json:"per_user,omitempty"`    // running plus waiting calls allowed per user (default 2)
+	MaxWaiting  int `json:"max_waiting,omitempty"` // queue length before new calls are refused (default 20)
+}
+
+// AIQuotaConfig caps the AI tokens (prompt plus generated) each chat and each
+// user may use per day. Zero means unlimited; bot admins are never limited.
+type AIQuotaConfig struct {
+	ChatDailyTokens int `json:"chat_daily_tokens,omitempty"`
+	UserDailyTokens int `json:"user_daily_tokens,omitempty"`
+}
+
+// AICacheConfig controls the cache of reusable AI answers, see CachedGenerate.
+type AICacheConfig struct {
+	Disabled   bool `json:"disabled,omitempty"`
+	TTLMinutes int  `json:"ttl_minutes,omitempty"` // how long an answer is reused (default 1440)
+	MaxEntries int  `json:"max_entries,omitempty"` // least recently used entries beyond this are dropped (default 1000)
 }
 
 type Configs struct {
-	DefaultBotID    string               `json:"default_bot_id"`
-	Bots            map[string]BotConfig `json:"bots"`
-	DefaultAIModel  string               `json:"default_ai_model"`
-	DefaultAIPrompt string               `json:"default_ai_prompt"`
+	DefaultBotID     string               `json:"default_bot_id"`
+	Bots             map[string]BotConfig `json:"bots"`
+	DefaultAIModel   string               `json:"default_ai_model"`
+	DefaultAIPrompt  string               `json:"default_ai_prompt"`
+	VisionModel      string               `json:"vision_model"` // model that answers about images, e.g. llava
+	EmbeddingModel   string               `json:"embedding_model"`
+	KnowledgeSources []KnowledgeSource    `json:"knowledge_sources"`
+	Retention        RetentionConfig      `json:"retention"`
+	Admins           []int64              `json:"admins"` // Telegram user IDs allowed to run admin commands
+
+	AIFallbacks       []AIBackend          `json:"ai_fallbacks"`        // tried in order when the chat's model fails
+	AIVisionFallbacks []AIBackend          `json:"ai_vision_fallbacks"` // tried instead of AIFallbacks for requests with images
+	AIBreaker         CircuitBreakerConfig `json:"ai_breaker"`
+	AIQueue           AIQueueConfig        `json:"ai_queue"`
+	AIQuota           AIQuotaConfig        `json:"ai_quota"`
+	AICache           AICacheConfig        `json:"ai_cache"`
+
+	Prompts     map[string]string `json:"prompts"`      // named prompt templates, see PromptData for variables
+	TypePrompts map[string]string `json:"type_prompts"` // message type -> prompt name
+
+	Classifier          string  `json:"classifier"`           // rules (default), ai, local or combined
+	ClassifierThreshold float64 `json:"classifier_threshold"` // minimum confidence before falling through to the next classifier
+}
+
+// IsAdmin reports whether userID is listed in Admins.
+func (c *Configs) IsAdmin(userID int64) bool {
+	for _, id := range c.Admins {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	defaultAIModel             = "llama2"
+	defaultVisionModel         = "llava"
+	defaultEmbeddingModel      = "nomic-embed-text"
+	defaultClassifier          = "rules"
+	defaultClassifierThreshold = 0.6
+)
+
+// AIModel returns the configured default model, falling back to llama2.
+func (c *Configs) AIModel() string {
+	if c.DefaultAIModel == "" {
+		return defaultAIModel
+	}
+	return c.DefaultAIModel
+}
+
+// AIVisionModel returns the configured vision model, falling back to llava.
+func (c *Configs) AIVisionModel() string {
+	if c.VisionModel == "" {
+		return defaultVisionModel
+	}
+	return c.VisionModel
 }
 
 func GetConfigPath() (string, error) {
@@ -46,10 +164,18 @@ func EnsureConfigFile() (*Configs, error) {
 	}
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		defaultConfig := &Configs{
-			DefaultBotID:    "",
-			Bots:            map[string]BotConfig{},
-			DefaultAIModel:  "llama2",
-			DefaultAIPrompt: "Reply in one concise sentence. Use two only if absolutely necessary, and use as few words as possible.",
+			DefaultBotID:     "",
+			Bots:             map[string]BotConfig{},
+			DefaultAIModel:   defaultAIModel,
+			DefaultAIPrompt:  "Reply in one concise sentence. Use two only if absolutely necessary, and use as few words as possible.",
+			VisionModel:      defaultVisionModel,
+			EmbeddingModel:   defaultEmbeddingModel,
+			KnowledgeSources: []KnowledgeSource{},
+			Prompts:          defaultPrompts(),
+			TypePrompts:      defaultTypePrompts(),
+
+			Classifier:          defaultClassifier,
+			ClassifierThreshold: defaultClassifierThreshold,
 		}
 		if err := SaveConfig(configPath, defaultConfig); err != nil {
 			return nil, err
@@ -70,6 +196,23 @@ func EnsureConfigFile() (*Configs, error) {
 		cfg.Bots = map[string]BotConfig{}
 		changed = true
 	}
+	if cfg.EmbeddingModel == "" {
+		cfg.EmbeddingModel = defaultEmbeddingModel
+		changed = true
+	}
+	if cfg.Prompts == nil {
+		cfg.Prompts = defaultPrompts()
+		changed = true
+	}
+	if cfg.TypePrompts == nil {
+		cfg.TypePrompts = defaultTypePrompts()
+		changed = true
+	}
+	if cfg.Classifier == "" {
+		cfg.Classifier = defaultClassifier
+		cfg.ClassifierThreshold = defaultClassifierThreshold
+		changed = true
+	}
 	if changed {
 		if err := SaveConfig(configPath, &cfg); err != nil {
 			return nil, err
@@ -97,3 +240,16 @@ func GetAppDir() string {
 	usr, _ := user.Current()
 	return filepath.Join(usr.HomeDir, ".ideasbglobe")
 }
+
+// currentConfig returns the live bot config when the bot is running, or the
+// config loaded from disk for CLI commands.
+func currentConfig() *Configs {
+	if botConfig != nil {
+		return botConfig
+	}
+	cfg, err := EnsureConfigFile()
+	if err != nil {
+		return &Configs{EmbeddingModel: defaultEmbeddingModel, Classifier: "rules"}
+	}
+	return cfg
+}
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"database/sql"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"os"
 	"path/filepath"
@@ -11,14 +12,19 @@ import (
 var DB *sql.DB
 
 type Message struct {
-	ID       int64
-	ChatID   int64
-	UserID   int64
-	Username string
-	Text     string
-	IsBot    bool
-	Type     string
-	Created  time.Time
+	ID        int64
+	ChatID    int64
+	MessageID int64 // Telegram message ID within the chat, 0 if unknown
+	UserID    int64
+	Username  string
+	Text      string
+	IsBot     bool
+	Type      string
+	Created   time.Time
+
+	Confidence  float64 // classifier confidence for Type, 0..1
+	LabelSource string  // classifier that chose Type: rules, ai, local or admin
+	Media       string  // attached media as "<kind>:<telegram file ID>", e.g. "photo:AgAD..."
 }
 
 func EnsureDatabase() error {
@@ -39,7 +45,12 @@ func InitDatabase() error {
 	if err != nil {
 		return err
 	}
-	_, err = DB.Exec(`
+	return initSchema()
+}
+
+// initSchema creates or upgrades every table in DB.
+func initSchema() error {
+	_, err := DB.Exec(`
 		CREATE TABLE IF NOT EXISTS messages (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			chat_id INTEGER,
@@ -62,13 +73,125 @@ func InitDatabase() error {
 			context TEXT
 		)
 	`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS migrations (
+			name TEXT PRIMARY KEY,
+			applied DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if err := ensureColumn("messages", "message_id", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn("messages", "confidence", "REAL"); err != nil {
+		return err
+	}
+	if err := ensureColumn("messages", "label_source", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn("messages", "media", "TEXT"); err != nil {
+		return err
+	}
+	if err := initAutoReplyTable(); err != nil {
+		return err
+	}
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_chat_created ON messages (chat_id, created)`)
+	if err != nil {
+		return err
+	}
+	if err := initEmbeddingTables(); err != nil {
+		return err
+	}
+	if err := initKnowledgeTables(); err != nil {
+		return err
+	}
+	if err := initStatsTables(); err != nil {
+		return err
+	}
+	if err := initRetentionTables(); err != nil {
+		return err
+	}
+	if err := initChatSettingsTable(); err != nil {
+		return err
+	}
+	if err := initSettingsTables(); err != nil {
+		return err
+	}
+	if err := initPromptColumns(); err != nil {
+		return err
+	}
+	if err := initCacheTables(); err != nil {
+		return err
+	}
+	if err := initDocumentTables(); err != nil {
+		return err
+	}
+	if err := initDigestColumns(); err != nil {
+		return err
+	}
+	if err := initNotesTables(); err != nil {
+		return err
+	}
+	return initChatSessionTables()
+}
+
+// ensureColumn adds a column to an existing table if it is missing, so older
+// databases pick up new fields without a separate migration step.
+func ensureColumn(table, column, decl string) error {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			ctype      string
+			notNull    bool
+			dflt       sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &primaryKey); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
 	return err
 }
 
-func SaveMessage(msg Message) error {
-	_, err := DB.Exec(`
-		INSERT INTO messages (chat_id, user_id, username, text, is_bot, type, created) 
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		msg.ChatID, msg.UserID, msg.Username, msg.Text, msg.IsBot, msg.Type, msg.Created)
+// migrationDone reports whether the one-off data migration name has run.
+func migrationDone(name string) (bool, error) {
+	var n int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM migrations WHERE name = ?`, name).Scan(&n)
+	return n > 0, err
+}
+
+// markMigrationDone records that the one-off data migration name has run.
+func markMigrationDone(name string) error {
+	_, err := DB.Exec(`INSERT OR IGNORE INTO migrations (name, applied) VALUES (?, ?)`, name, time.Now().UTC())
 	return err
 }
+
+// SaveMessage stores msg and returns its row ID.
+func SaveMessage(msg Message) (int64, error) {
+	res, err := DB.Exec(`
+		INSERT INTO messages (chat_id, message_id, user_id, username, text, is_bot, type, confidence, label_source, media, created) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		msg.ChatID, msg.MessageID, msg.UserID, msg.Username, msg.Text, msg.IsBot, msg.Type, msg.Confidence, msg.LabelSource, msg.Media, msg.Created.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
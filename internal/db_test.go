@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// useTestDB points DB at a fresh in-memory database for the rest of the test.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // every connection to :memory: is a database of its own
+	old := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = old
+		db.Close()
+	})
+	if err := initSchema(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,335 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTldrMessages = 100
+	maxTldrMessages     = 1000
+	maxTranscriptChars  = 24000
+	digestListItems     = 5
+)
+
+// ParseTldrArgs turns /tldr arguments into a message filter for chatID:
+// nothing for the last 100 messages, "N" or "N messages" for the last N, or
+// "[since] <time>" (see ParseTimeArg) for everything since then. label
+// describes the selection for the summary's heading.
+func ParseTldrArgs(chatID int64, args string) (f MessageFilter, label string, err error) {
+	f.ChatID = chatID
+	args = strings.ToLower(strings.TrimSpace(args))
+	if args == "" {
+		f.Limit = defaultTldrMessages
+		return f, fmt.Sprintf("the last %d messages", f.Limit), nil
+	}
+	fields := strings.Fields(args)
+	if n, err := strconv.Atoi(fields[0]); err == nil && (len(fields) == 1 || strings.HasPrefix(fields[1], "m")) {
+		if n <= 0 || n > maxTldrMessages {
+			return f, "", fmt.Errorf("pick between 1 and %d messages", maxTldrMessages)
+		}
+		f.Limit = n
+		return f, fmt.Sprintf("the last %d messages", n), nil
+	}
+	since := strings.TrimSpace(strings.TrimPrefix(args, "since"))
+	if f.Since, err = ParseTimeArg(since); err != nil || f.Since.IsZero() {
+		return f, "", fmt.Errorf("usage: /tldr [N messages | since 2h | since 2006-01-02]")
+	}
+	f.Limit = maxTldrMessages
+	return f, "messages since " + f.Since.Local().Format("Jan 2 15:04"), nil
+}
+
+// conversationMessages drops bot messages, commands and empty messages, which
+// say nothing about the discussion.
+func conversationMessages(msgs []Message) []Message {
+	var out []Message
+	for _, m := range msgs {
+		text := strings.TrimSpace(m.Text)
+		if m.IsBot || strings.HasPrefix(text, "/") || (text == "" && m.Media == "") {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// ChatTranscript renders messages as "[15:04] name: text" lines for a prompt,
+// keeping the most recent ones that fit in maxChars.
+func ChatTranscript(msgs []Message, maxChars int) string {
+	var lines []string
+	size := 0
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		name := m.Username
+		if name == "" {
+			name = fmt.Sprintf("user %d", m.UserID)
+		}
+		text := strings.ReplaceAll(strings.TrimSpace(m.Text), "\n", " ")
+		if kind, _, ok := strings.Cut(m.Media, ":"); ok {
+			text = strings.TrimSpace("[" + kind + "] " + text)
+		}
+		if r := []rune(text); len(r) > 500 {
+			text = string(r[:500]) + "…"
+		}
+		line := fmt.Sprintf("[%s] %s: %s", m.Created.Local().Format("15:04"), name, text)
+		if size+len(line) > maxChars {
+			break
+		}
+		size += len(line) + 1
+		lines = append(lines, line)
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SummarizeMessages asks the model in s for a summary of msgs using the named
+// prompt, with the transcript as the prompt's Context and label as its
+// Message. ctx carries the caller's AITask.
+func SummarizeMessages(ctx context.Context, s AISettings, prompt, chatTitle, label string, msgs []Message) (string, error) {
+	tmpl, _ := LookupPrompt(currentConfig(), prompt)
+	if chatTitle == "" {
+		chatTitle = "a private chat"
+	}
+	text, err := RenderPrompt(tmpl, PromptData{
+		Chat:    chatTitle,
+		Message: label,
+		Context: ChatTranscript(msgs, maxTranscriptChars),
+		Time:    time.Now().Format("2006-01-02 15:04"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("prompt %q: %w", prompt, err)
+	}
+	resp, err := GenerateWithFallback(ctx, s.Request(text))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Response), nil
+}
+
+// Digest frequencies.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSchedule is when a chat's digest is posted, in server time.
+type DigestSchedule struct {
+	Frequency string
+	Weekday   time.Weekday // weekly digests only
+	Hour      int
+	Minute    int
+}
+
+// ParseDigestSchedule parses "daily [HH:MM]" or "weekly [day] [HH:MM]". The
+// time defaults to 09:00 and the day to Monday.
+func ParseDigestSchedule(s string) (DigestSchedule, error) {
+	d := DigestSchedule{Weekday: time.Monday, Hour: 9}
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return d, fmt.Errorf("usage: daily [HH:MM] or weekly [day] [HH:MM]")
+	}
+	switch fields[0] {
+	case DigestDaily, DigestWeekly:
+		d.Frequency = fields[0]
+	default:
+		return d, fmt.Errorf("unknown frequency %q: use daily or weekly", fields[0])
+	}
+	for _, f := range fields[1:] {
+		if t, err := time.Parse("15:04", f); err == nil {
+			d.Hour, d.Minute = t.Hour(), t.Minute()
+			continue
+		}
+		day, ok := parseWeekday(f)
+		if !ok || d.Frequency != DigestWeekly {
+			return d, fmt.Errorf("invalid digest time or day %q", f)
+		}
+		d.Weekday = day
+	}
+	return d, nil
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if len(s) >= 3 && strings.HasPrefix(name, s) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+func (d DigestSchedule) String() string {
+	if d.Frequency == DigestWeekly {
+		return fmt.Sprintf("weekly %s %02d:%02d", strings.ToLower(d.Weekday.String()[:3]), d.Hour, d.Minute)
+	}
+	return fmt.Sprintf("daily %02d:%02d", d.Hour, d.Minute)
+}
+
+// Period is how far back each digest looks.
+func (d DigestSchedule) Period() time.Duration {
+	if d.Frequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Next returns the first scheduled time strictly after t.
+func (d DigestSchedule) Next(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), d.Hour, d.Minute, 0, 0, t.Location())
+	if d.Frequency == DigestWeekly {
+		next = next.AddDate(0, 0, (int(d.Weekday)-int(next.Weekday())+7)%7)
+	}
+	for !next.After(t) {
+		if d.Frequency == DigestWeekly {
+			next = next.AddDate(0, 0, 7)
+		} else {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+func initDigestColumns() error {
+	if err := ensureColumn("chat_settings", "digest", "TEXT"); err != nil {
+		return err
+	}
+	return ensureColumn("chat_settings", "digest_last", "DATETIME")
+}
+
+// ChatDigest is a chat's digest schedule and when it last ran.
+type ChatDigest struct {
+	ChatID   int64
+	Schedule DigestSchedule
+	Last     time.Time
+}
+
+// GetChatDigest returns chatID's digest schedule, or nil if it has none.
+func GetChatDigest(chatID int64) (*ChatDigest, error) {
+	var spec sql.NullString
+	var last sql.NullTime
+	err := DB.QueryRow(`SELECT digest, digest_last FROM chat_settings WHERE chat_id = ?`, chatID).Scan(&spec, &last)
+	if err == sql.ErrNoRows || (err == nil && spec.String == "") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := ParseDigestSchedule(spec.String)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatDigest{ChatID: chatID, Schedule: s, Last: last.Time}, nil
+}
+
+// ScheduledDigests returns every chat with a digest schedule.
+func ScheduledDigests() ([]ChatDigest, error) {
+	rows, err := DB.Query(`SELECT chat_id, digest, digest_last FROM chat_settings WHERE COALESCE(digest, '') != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChatDigest
+	for rows.Next() {
+		var d ChatDigest
+		var spec string
+		var last sql.NullTime
+		if err := rows.Scan(&d.ChatID, &spec, &last); err != nil {
+			return nil, err
+		}
+		if d.Schedule, err = ParseDigestSchedule(spec); err != nil {
+			continue
+		}
+		d.Last = last.Time
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// SetChatDigest schedules chatID's digest, or turns it off when s is nil.
+// The schedule counts as having just run, so the first digest is the next
+// scheduled one.
+func SetChatDigest(chatID int64, s *DigestSchedule) error {
+	var spec interface{}
+	if s != nil {
+		spec = s.String()
+	}
+	now := time.Now().UTC()
+	_, err := DB.Exec(`INSERT INTO chat_settings (chat_id, digest, digest_last, updated) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET digest = excluded.digest, digest_last = excluded.digest_last, updated = excluded.updated`,
+		chatID, spec, now, now)
+	return err
+}
+
+// MarkDigestSent records that chatID's digest ran at t.
+func MarkDigestSent(chatID int64, t time.Time) error {
+	_, err := DB.Exec(`UPDATE chat_settings SET digest_last = ? WHERE chat_id = ?`, t.UTC(), chatID)
+	return err
+}
+
+// BuildDigest assembles a chat's digest of the messages since the given
+// time: an AI summary of the key discussions and open questions, followed by
+// the issues and feature requests the classifier detected. If the AI fails
+// the digest still lists what was detected and aiErr reports the failure.
+// An empty digest means there was nothing to report.
+func BuildDigest(ctx context.Context, chatID int64, chatTitle, title string, since time.Time) (digest string, aiErr error, err error) {
+	msgs, err := QueryMessages(MessageFilter{ChatID: chatID, Since: since})
+	if err != nil {
+		return "", nil, err
+	}
+	msgs = conversationMessages(msgs)
+	if len(msgs) == 0 {
+		return "", nil, nil
+	}
+	people := map[int64]bool{}
+	for _, m := range msgs {
+		people[m.UserID] = true
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🗞 %s — %d messages from %d people\n", title, len(msgs), len(people))
+	summary, aiErr := SummarizeMessages(ctx, ResolveAISettings(chatID, 0), "digest", chatTitle, title, msgs)
+	if aiErr == nil && summary != "" {
+		b.WriteString("\n" + summary + "\n")
+	}
+	for _, section := range []struct{ label, heading string }{
+		{LabelIssue, "🐞 Issues reported"},
+		{LabelFeatureRequest, "💡 Feature requests"},
+		{LabelQuestion, "❓ Questions asked"},
+	} {
+		if section.label == LabelQuestion && aiErr == nil {
+			continue // the summary already covers the open ones
+		}
+		var items []string
+		for _, m := range msgs {
+			if m.Type == section.label {
+				items = append(items, digestItem(m))
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", section.heading, len(items))
+		if len(items) > digestListItems {
+			items = append(items[:digestListItems], fmt.Sprintf("… and %d more", len(items)-digestListItems))
+		}
+		b.WriteString(strings.Join(items, "\n") + "\n")
+	}
+	return strings.TrimSpace(b.String()), aiErr, nil
+}
+
+func digestItem(m Message) string {
+	name := m.Username
+	if name == "" {
+		name = fmt.Sprintf("user %d", m.UserID)
+	}
+	text := strings.Join(strings.Fields(m.Text), " ")
+	if r := []rune(text); len(r) > 100 {
+		text = string(r[:100]) + "…"
+	}
+	return fmt.Sprintf("• %s: %s", name, text)
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const digestUsage = `Usage:
+/digest — show this chat's digest schedule
+/digest daily [HH:MM] — post a digest of the last day every day (default 09:00)
+/digest weekly [day] [HH:MM] — post a digest of the last week (default Monday 09:00)
+/digest now — post a digest of the last day now
+/digest off — stop posting digests`
+
+// handleTldrCommand summarizes recent chat history: /tldr [N messages | since
+// <time>]. It runs in its own goroutine and may wait in the AI queue.
+func handleTldrCommand(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	f, label, err := ParseTldrArgs(m.Chat.ID, args)
+	if err != nil {
+		reply(err.Error())
+		return
+	}
+	msgs, err := QueryMessages(f)
+	if err != nil {
+		reply("[TL;DR error] " + err.Error())
+		return
+	}
+	msgs = conversationMessages(msgs)
+	if len(msgs) == 0 {
+		reply("Nothing to summarize: no messages stored for " + label + ".")
+		return
+	}
+	bot.Send(tgbotapi.NewChatAction(m.Chat.ID, tgbotapi.ChatTyping))
+	task := aiTaskFor(m, "tldr")
+	task.OnWait = queueNotifier(bot, m)
+	summary, err := SummarizeMessages(WithAITask(ctx, task), ResolveAISettings(m.Chat.ID, m.From.ID), "tldr", m.Chat.Title, label, msgs)
+	if text, ok := aiLimitText(err); ok {
+		if text != "" {
+			reply(text)
+		}
+		return
+	}
+	if errors.Is(err, ErrAIUnavailable) {
+		reply(DegradedMessage)
+		return
+	}
+	if err != nil {
+		reply("[AI error] " + err.Error())
+		return
+	}
+	sendLongMessage(bot, m.Chat.ID, fmt.Sprintf("📝 TL;DR of %s (%d messages)\n\n%s", label, len(msgs), summary))
+}
+
+// handleDigestCommand shows or changes the chat's digest schedule, or posts a
+// digest now. Changes are for chat admins.
+func handleDigestCommand(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	args = strings.ToLower(strings.TrimSpace(args))
+	if args == "" {
+		d, err := GetChatDigest(m.Chat.ID)
+		switch {
+		case err != nil:
+			reply("[Digest error] " + err.Error())
+		case d == nil:
+			reply("No digest is scheduled for this chat.\n\n" + digestUsage)
+		default:
+			reply(fmt.Sprintf("🗞 Digest: %s, next on %s.\n\n%s",
+				d.Schedule, d.Schedule.Next(time.Now()).Format("Mon Jan 2 15:04"), digestUsage))
+		}
+		return
+	}
+	if !canManageChat(bot, m.Chat, m.From.ID) {
+		reply("⛔ Only chat admins can change the digest.")
+		return
+	}
+	switch args {
+	case "off":
+		if err := SetChatDigest(m.Chat.ID, nil); err != nil {
+			reply("[Digest error] " + err.Error())
+			return
+		}
+		reply("✅ Digests turned off for this chat.")
+	case "now":
+		go postDigest(ctx, bot, m.Chat.ID, m.Chat.Title, "the last day", time.Now().Add(-24*time.Hour), true)
+	default:
+		s, err := ParseDigestSchedule(args)
+		if err != nil {
+			reply(err.Error() + "\n\n" + digestUsage)
+			return
+		}
+		if err := SetChatDigest(m.Chat.ID, &s); err != nil {
+			reply("[Digest error] " + err.Error())
+			return
+		}
+		reply(fmt.Sprintf("✅ Digest scheduled: %s. The first one is on %s.", s, s.Next(time.Now()).Format("Mon Jan 2 15:04")))
+	}
+}
+
+// postDigest builds and sends a digest of chatID's messages since the given
+// time. Requested digests report problems to the chat; scheduled ones only
+// log them and stay quiet when there was no activity.
+func postDigest(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, chatTitle, period string, since time.Time, requested bool) {
+	task := AITask{ChatID: chatID, Kind: "digest"}
+	digest, aiErr, err := BuildDigest(WithAITask(ctx, task), chatID, chatTitle, "Digest of "+period, since)
+	if err != nil {
+		log.Printf("Error building digest for chat %d: %v", chatID, err)
+		if requested {
+			bot.Send(tgbotapi.NewMessage(chatID, "[Digest error] "+err.Error()))
+		}
+		return
+	}
+	if aiErr != nil {
+		log.Printf("Digest for chat %d without AI summary: %v", chatID, aiErr)
+	}
+	if digest == "" {
+		if requested {
+			bot.Send(tgbotapi.NewMessage(chatID, "Nothing to report: no messages in "+period+"."))
+		}
+		return
+	}
+	sendLongMessage(bot, chatID, digest)
+}
+
+// StartDigestScheduler posts each chat's scheduled digest once its time has
+// come, checking every minute until ctx is cancelled. Silent chats are
+// skipped but still marked as done.
+func StartDigestScheduler(ctx context.Context, bot *tgbotapi.BotAPI) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		digests, err := ScheduledDigests()
+		if err != nil {
+			log.Printf("Digest scheduler: %v", err)
+			continue
+		}
+		now := time.Now()
+		for _, d := range digests {
+			if d.Schedule.Next(d.Last.Local()).After(now) {
+				continue
+			}
+			if err := MarkDigestSent(d.ChatID, now); err != nil {
+				log.Printf("Digest scheduler: %v", err)
+				continue
+			}
+			if GetChatSettings(d.ChatID).Mode == ModeSilent {
+				continue
+			}
+			title := ""
+			if chat, err := bot.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: d.ChatID}}); err == nil {
+				title = chat.Title
+			}
+			period := "the last day"
+			if d.Schedule.Frequency == DigestWeekly {
+				period = "the last week"
+			}
+			log.Printf("[DIGEST] chat %d: posting %s digest", d.ChatID, d.Schedule.Frequency)
+			go postDigest(ctx, bot, d.ChatID, title, period, now.Add(-d.Schedule.Period()), false)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDigestSchedule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string // DigestSchedule.String, empty when an error is expected
+		wantErr bool
+	}{
+		{"daily", "daily 09:00", false},
+		{"daily 18:30", "daily 18:30", false},
+		{"weekly", "weekly mon 09:00", false},
+		{"Weekly Friday 17:00", "weekly fri 17:00", false},
+		{"weekly 08:15 sun", "weekly sun 08:15", false},
+		{"", "", true},
+		{"monthly", "", true},
+		{"daily friday", "", true},    // only weekly digests take a day
+		{"daily 25:00", "", true},     // hour out of range
+		{"weekly fr 10:00", "", true}, // day names need at least three letters
+	}
+	for _, tt := range tests {
+		d, err := ParseDigestSchedule(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDigestSchedule(%q) = %s, want an error", tt.in, d)
+			}
+			continue
+		}
+		if err != nil || d.String() != tt.want {
+			t.Errorf("ParseDigestSchedule(%q) = %s, %v, want %s", tt.in, d, err, tt.want)
+		}
+	}
+}
+
+func TestDigestScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// 2024-01-01 is a Monday.
+	tests := []struct {
+		name     string
+		schedule string
+		now      string
+		want     string
+	}{
+		{"weekly, same day before the time", "weekly mon 09:00", "2024-01-01 08:00", "2024-01-01 09:00"},
+		{"weekly, same day after the time", "weekly mon 09:00", "2024-01-01 10:00", "2024-01-08 09:00"},
+		{"weekly, exactly on time", "weekly mon 09:00", "2024-01-01 09:00", "2024-01-08 09:00"},
+		{"weekly, later in the week", "weekly sun 18:00", "2024-01-01 10:00", "2024-01-07 18:00"},
+		{"weekly, wraps to next week", "weekly mon 09:00", "2024-01-06 12:00", "2024-01-08 09:00"},
+		{"daily, before the time", "daily 09:00", "2024-01-01 08:59", "2024-01-01 09:00"},
+		{"daily, past the time", "daily 09:00", "2024-01-01 10:00", "2024-01-02 09:00"},
+		{"daily, across the new year", "daily 23:30", "2024-12-31 23:45", "2025-01-01 23:30"},
+	}
+	for _, tt := range tests {
+		d, err := ParseDigestSchedule(tt.schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Next(at(tt.now)); !got.Equal(at(tt.want)) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tt.name, tt.now, got.Format("2006-01-02 15:04 Mon"), tt.want)
+		}
+	}
+}
+
+func TestParseTldrArgs(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		args      string
+		wantLimit int
+		wantSince time.Time // zero for a message count
+		wantErr   bool
+	}{
+		{"", defaultTldrMessages, time.Time{}, false},
+		{"50", 50, time.Time{}, false},
+		{"50 messages", 50, time.Time{}, false},
+		{"20 msgs", 20, time.Time{}, false},
+		{"since 2h", maxTldrMessages, now.Add(-2 * time.Hour), false},
+		{"2h", maxTldrMessages, now.Add(-2 * time.Hour), false},
+		{"since 1d", maxTldrMessages, now.Add(-24 * time.Hour), false},
+		{"since 2024-01-02", maxTldrMessages, time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local), false},
+		{"0", 0, time.Time{}, true},
+		{"1001 messages", 0, time.Time{}, true},
+		{"-5", 0, time.Time{}, true},
+		{"since", 0, time.Time{}, true},
+		{"since yesterday", 0, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		f, _, err := ParseTldrArgs(42, tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTldrArgs(%q) = %+v, want an error", tt.args, f)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTldrArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if f.ChatID != 42 || f.Limit != tt.wantLimit {
+			t.Errorf("ParseTldrArgs(%q) = chat %d, limit %d, want chat 42, limit %d", tt.args, f.ChatID, f.Limit, tt.wantLimit)
+		}
+		if d := f.Since.Sub(tt.wantSince); d < -time.Minute || d > time.Minute {
+			t.Errorf("ParseTldrArgs(%q) since = %s, want %s", tt.args, f.Since, tt.wantSince)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	maxDocumentBytes   = 1 << 20
+	maxDocumentPrompt  = 24000         // characters of a document put into a prompt
+	documentContextTTL = 2 * time.Hour // how long /ai keeps using a chat's latest document
+)
+
+// documentExts are the extra file types, besides those indexed as knowledge,
+// that the bot reads when they are posted as documents.
+var documentExts = map[string]bool{
+	".log": true, ".out": true, ".trace": true, ".markdown": true, ".jsonl": true, ".csv": true,
+	".xml": true, ".diff": true, ".patch": true, ".ini": true, ".conf": true, ".cfg": true,
+}
+
+// documentMimeTypes are non-text/* MIME types that are still plain text.
+var documentMimeTypes = map[string]bool{
+	"application/json": true, "application/xml": true, "application/x-yaml": true,
+	"application/x-sh": true, "application/sql": true, "application/javascript": true,
+}
+
+// IsTextDocument reports whether a file with this name and MIME type is read
+// as text.
+func IsTextDocument(name, mimeType string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return defaultKnowledgeExts[ext] || documentExts[ext] ||
+		strings.HasPrefix(mimeType, "text/") || documentMimeTypes[mimeType]
+}
+
+// ExtractDocumentText returns data as text, or an error if it looks binary.
+func ExtractDocumentText(data []byte) (string, error) {
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return "", fmt.Errorf("the file looks binary")
+	}
+	text := strings.ToValidUTF8(string(data), "\uFFFD")
+	text = strings.ReplaceAll(strings.TrimPrefix(text, "\uFEFF"), "\r\n", "\n")
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("the file is empty")
+	}
+	return text, nil
+}
+
+// documentExcerpt fits text into max characters for a prompt. Long documents
+// keep their beginning and end, where logs and stack traces put the
+// interesting parts, and mark how many lines were left out.
+func documentExcerpt(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	head, tail := text[:max/3], text[len(text)-max*2/3:]
+	if i := strings.LastIndex(head, "\n"); i > 0 {
+		head = head[:i]
+	}
+	if i := strings.Index(tail, "\n"); i >= 0 {
+		tail = tail[i+1:]
+	}
+	omitted := strings.Count(text, "\n") - strings.Count(head, "\n") - strings.Count(tail, "\n")
+	return strings.ToValidUTF8(fmt.Sprintf("%s\n[… %d lines omitted …]\n%s", head, omitted, tail), "")
+}
+
+func initDocumentTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS chat_documents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER,
+			user_id INTEGER,
+			message_id INTEGER,
+			summary_message_id INTEGER DEFAULT 0,
+			file_name TEXT,
+			mime_type TEXT,
+			size INTEGER,
+			content TEXT,
+			summary TEXT,
+			active BOOLEAN DEFAULT 1,
+			created DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_chat_documents_chat_created ON chat_documents (chat_id, created)`)
+	return err
+}
+
+// ChatDocument is a text document posted in a chat.
+type ChatDocument struct {
+	ID        int64
+	ChatID    int64
+	UserID    int64
+	MessageID int64
+	FileName  string
+	MimeType  string
+	Size      int64
+	Content   string
+	Summary   string
+	Created   time.Time
+}
+
+// SaveChatDocument stores d and sets its ID. It becomes the chat's current
+// document for follow-up questions.
+func SaveChatDocument(d *ChatDocument) error {
+	if d.Created.IsZero() {
+		d.Created = time.Now()
+	}
+	res, err := DB.Exec(`INSERT INTO chat_documents (chat_id, user_id, message_id, file_name, mime_type, size, content, created)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ChatID, d.UserID, d.MessageID, d.FileName, d.MimeType, d.Size, d.Content, d.Created.UTC())
+	if err != nil {
+		return err
+	}
+	d.ID, err = res.LastInsertId()
+	return err
+}
+
+// SetDocumentSummary stores a document's summary and the message it was
+// sent in, so replies to the summary find the document too.
+func SetDocumentSummary(id int64, summary string, messageID int64) error {
+	_, err := DB.Exec(`UPDATE chat_documents SET summary = ?, summary_message_id = ? WHERE id = ?`, summary, messageID, id)
+	return err
+}
+
+const documentColumns = `id, chat_id, user_id, message_id, COALESCE(file_name, ''), COALESCE(mime_type, ''), size,
+	COALESCE(content, ''), COALESCE(summary, ''), created`
+
+func scanChatDocument(row *sql.Row) (*ChatDocument, error) {
+	var d ChatDocument
+	err := row.Scan(&d.ID, &d.ChatID, &d.UserID, &d.MessageID, &d.FileName, &d.MimeType, &d.Size, &d.Content, &d.Summary, &d.Created)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DocumentForMessage returns the document posted as, or summarised in,
+// messageID in chatID, or nil.
+func DocumentForMessage(chatID, messageID int64) (*ChatDocument, error) {
+	return scanChatDocument(DB.QueryRow(`SELECT `+documentColumns+` FROM chat_documents
+		WHERE chat_id = ? AND (message_id = ? OR summary_message_id = ?) ORDER BY id DESC LIMIT 1`, chatID, messageID, messageID))
+}
+
+// CurrentChatDocument returns the chat's most recent document still in use
+// for follow-up questions, or nil.
+func CurrentChatDocument(chatID int64) (*ChatDocument, error) {
+	return scanChatDocument(DB.QueryRow(`SELECT `+documentColumns+` FROM chat_documents
+		WHERE chat_id = ? AND active AND created >= ? ORDER BY id DESC LIMIT 1`, chatID, time.Now().UTC().Add(-documentContextTTL)))
+}
+
+// ClearChatDocuments stops using the chat's documents as context for /ai.
+func ClearChatDocuments(chatID int64) (int64, error) {
+	res, err := DB.Exec(`UPDATE chat_documents SET active = 0 WHERE chat_id = ? AND active`, chatID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
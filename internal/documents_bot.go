@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const docUsage = `Usage:
+/doc — show the document /ai questions currently refer to
+/doc clear — stop using posted documents as context
+Reply to a document or its summary with /ai <question> to ask about it.`
+
+// messageMedia returns the media reference stored with m: its image, or
+// "document:<file ID>" for other files.
+func messageMedia(m *tgbotapi.Message) string {
+	if _, media, ok := messageImage(m); ok {
+		return media
+	}
+	if m.Document != nil {
+		return "document:" + m.Document.FileID
+	}
+	return ""
+}
+
+// handleDocumentMessage reads a text document posted in a chat, stores it for
+// follow-up /ai questions and replies with a summary: always when addressed
+// (see mediaAddressed), otherwise only in active chats outside cooldown and quiet hours. Problems
+// with the file are only reported when addressed. It runs in its own
+// goroutine.
+func handleDocumentMessage(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, msgType string, settings ChatSettings) {
+	d := m.Document
+	addressed := mediaAddressed(bot.Self, m, settings)
+	reply := func(text string) (tgbotapi.Message, error) {
+		msg := tgbotapi.NewMessage(m.Chat.ID, text)
+		msg.ReplyToMessageID = m.MessageID
+		return bot.Send(msg)
+	}
+	fail := func(text string) {
+		if addressed {
+			reply("📄 " + text)
+		}
+	}
+	if !IsTextDocument(d.FileName, d.MimeType) {
+		fail("I can only read text files such as logs, Markdown, JSON and source code.")
+		return
+	}
+	if int64(d.FileSize) > maxDocumentBytes {
+		fail(fmt.Sprintf("%s is too large for me to read (%s, limit %s).", d.FileName, FormatBytes(int64(d.FileSize)), FormatBytes(maxDocumentBytes)))
+		return
+	}
+	dlCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	data, err := downloadTelegramFile(dlCtx, bot, d.FileID, maxDocumentBytes)
+	cancel()
+	if err != nil {
+		log.Printf("Error downloading document %s: %v", d.FileName, err)
+		fail("Couldn't download " + d.FileName + ": " + err.Error())
+		return
+	}
+	text, err := ExtractDocumentText(data)
+	if err != nil {
+		fail("Couldn't read " + d.FileName + ": " + err.Error())
+		return
+	}
+	doc := &ChatDocument{
+		ChatID:    m.Chat.ID,
+		UserID:    m.From.ID,
+		MessageID: int64(m.MessageID),
+		FileName:  d.FileName,
+		MimeType:  d.MimeType,
+		Size:      int64(len(data)),
+		Content:   text,
+	}
+	if err := SaveChatDocument(doc); err != nil {
+		log.Printf("Error saving document %s: %v", d.FileName, err)
+		fail("Couldn't store " + d.FileName + ": " + err.Error())
+		return
+	}
+	log.Printf("[DOC] chat %d: stored %s (%s) from %d", m.Chat.ID, d.FileName, FormatBytes(doc.Size), m.From.ID)
+
+	if !addressed && (settings.Mode != ModeActive || !allowUnsolicitedReply(settings, time.Now())) {
+		return
+	}
+	ai := ResolveAISettings(m.Chat.ID, m.From.ID)
+	task := aiTaskFor(m, "document")
+	if addressed {
+		task.OnWait = queueNotifier(bot, m)
+	} else {
+		task.NoWait = true
+	}
+	bot.Send(tgbotapi.NewChatAction(m.Chat.ID, tgbotapi.ChatTyping))
+	prompt := renderMessagePrompt("document", m, msgType, stripBotAddress(bot.Self, m.Caption), doc)
+	resp, err := GenerateWithFallback(WithAITask(ctx, task), ai.Request(prompt))
+	if err != nil {
+		if !addressed {
+			log.Printf("Error summarizing document %s: %v", d.FileName, err)
+			return
+		}
+		if text, ok := aiLimitText(err); ok {
+			if text != "" {
+				reply(text)
+			}
+		} else if errors.Is(err, ErrAIUnavailable) {
+			reply(DegradedMessage)
+		} else {
+			reply("[AI error] " + err.Error())
+		}
+		return
+	}
+	summary := ai.Truncate(strings.TrimSpace(resp.Response))
+	sent, err := reply(fmt.Sprintf("📄 %s\n\n%s\n\nReply to this message with /ai <question> to ask about it.", d.FileName, summary))
+	if err != nil {
+		return
+	}
+	if !addressed {
+		markSmartReply(m.Chat.ID, time.Now())
+	}
+	if err := SetDocumentSummary(doc.ID, summary, int64(sent.MessageID)); err != nil {
+		log.Printf("Error saving document summary: %v", err)
+	}
+}
+
+// followUpDocument returns the document an /ai message asks about: the one
+// it replies to (or whose summary it replies to), else the chat's current
+// document, else nil.
+func followUpDocument(m *tgbotapi.Message) *ChatDocument {
+	if r := m.ReplyToMessage; r != nil {
+		if doc, err := DocumentForMessage(m.Chat.ID, int64(r.MessageID)); err == nil && doc != nil {
+			return doc
+		}
+	}
+	doc, err := CurrentChatDocument(m.Chat.ID)
+	if err != nil {
+		log.Printf("Error loading chat document: %v", err)
+	}
+	return doc
+}
+
+// handleDocCommand shows or clears the chat's current document.
+func handleDocCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		doc, err := CurrentChatDocument(m.Chat.ID)
+		if err != nil {
+			reply("[Doc error] " + err.Error())
+			return
+		}
+		if doc == nil {
+			reply("No document is in use here. Post a text file to start.\n\n" + docUsage)
+			return
+		}
+		reply(fmt.Sprintf("📄 /ai questions here refer to %s (%s, posted %s).\n\n%s",
+			doc.FileName, FormatBytes(doc.Size), doc.Created.Local().Format("15:04"), docUsage))
+	case "clear", "off":
+		n, err := ClearChatDocuments(m.Chat.ID)
+		if err != nil {
+			reply("[Doc error] " + err.Error())
+			return
+		}
+		if n == 0 {
+			reply("No document was in use.")
+			return
+		}
+		reply("✅ /ai no longer uses posted documents until the next one.")
+	default:
+		reply(docUsage)
+	}
+}
@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// embeddingBatchSize is how many messages the indexer embeds per pass.
+const embeddingBatchSize = 50
+
+// indexerWake is signalled whenever a new message is stored so the background
+// indexer picks it up without waiting for the next tick.
+var indexerWake = make(chan struct{}, 1)
+
+// RecallResult is a stored message together with its similarity to a query.
+type RecallResult struct {
+	Message Message
+	Score   float64
+}
+
+func initEmbeddingTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS message_embeddings (
+			message_id INTEGER PRIMARY KEY,
+			chat_id INTEGER,
+			model TEXT,
+			vector BLOB,
+			created DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_message_embeddings_chat ON message_embeddings (chat_id, model)`)
+	return err
+}
+
+// NotifyIndexer wakes the background embedding indexer. It never blocks.
+func NotifyIndexer() {
+	select {
+	case indexerWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartEmbeddingIndexer embeds new messages in the background until ctx is done.
+func StartEmbeddingIndexer(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := IndexPendingMessages(ctx, embeddingBatchSize)
+			if err != nil {
+				log.Printf("Embedding indexer: %v", err)
+				break
+			}
+			if n < embeddingBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-indexerWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// IndexPendingMessages embeds up to limit messages that have no vector for the
+// configured embedding model yet and returns how many were processed. Commands
+// are skipped so /recall queries don't match themselves. Messages Ollama
+// cannot embed are recorded with a NULL vector and not retried.
+func IndexPendingMessages(ctx context.Context, limit int) (int, error) {
+	model := currentConfig().EmbeddingModel
+	rows, err := DB.Query(`
+		SELECT m.id, m.chat_id, m.text FROM messages m
+		LEFT JOIN message_embeddings e ON e.message_id = m.id
+		WHERE (e.message_id IS NULL OR e.model != ?) AND TRIM(COALESCE(m.text, '')) != '' AND m.text NOT LIKE '/%'
+		ORDER BY m.id LIMIT ?`, model, limit)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		id, chatID int64
+		text       string
+	}
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.chatID, &p.text); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		todo = append(todo, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	indexed := 0
+	for _, p := range todo {
+		if r := []rune(p.text); len(r) > 2000 {
+			p.text = string(r[:2000])
+		}
+		var vector []byte
+		vec, err := OllamaEmbed(ctx, p.text, model)
+		if err != nil {
+			if errors.As(err, new(*url.Error)) || ctx.Err() != nil ||
+				errors.Is(err, ErrAIQueueFull) || errors.Is(err, ErrAIBusy) {
+				return indexed, err // Ollama is unreachable or busy; retry the batch later
+			}
+			// Store the message without a vector so it doesn't block the queue.
+			log.Printf("Embedding indexer: skipping message %d: %v", p.id, err)
+		} else {
+			vector = encodeVector(vec)
+		}
+		_, err = DB.Exec(`INSERT OR REPLACE INTO message_embeddings (message_id, chat_id, model, vector) VALUES (?, ?, ?, ?)`,
+			p.id, p.chatID, model, vector)
+		if err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+	return indexed, nil
+}
+
+// SearchSimilarMessages returns the limit stored messages most similar to query.
+// A chatID of 0 searches across all chats. ctx may carry the caller's AITask.
+func SearchSimilarMessages(ctx context.Context, chatID int64, query string, limit int) ([]RecallResult, error) {
+	model := currentConfig().EmbeddingModel
+	qvec, err := OllamaEmbed(ctx, query, model)
+	if err != nil {
+		return nil, err
+	}
+	sqlQuery := `SELECT m.id, m.chat_id, COALESCE(m.message_id, 0), m.user_id, COALESCE(m.username, ''), COALESCE(m.text, ''), m.is_bot, COALESCE(m.type, ''), m.created, e.vector
+		FROM message_embeddings e JOIN messages m ON m.id = e.message_id
+		WHERE e.model = ? AND e.vector IS NOT NULL`
+	args := []interface{}{model}
+	if chatID != 0 {
+		sqlQuery += ` AND e.chat_id = ?`
+		args = append(args, chatID)
+	}
+	rows, err := DB.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []RecallResult
+	for rows.Next() {
+		var m Message
+		var blob []byte
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.MessageID, &m.UserID, &m.Username, &m.Text, &m.IsBot, &m.Type, &m.Created, &blob); err != nil {
+			return nil, err
+		}
+		results = append(results, RecallResult{Message: m, Score: cosineSimilarity(qvec, decodeVector(blob))})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// FormatRecallResults renders search results as plain text for chat replies and the CLI.
+func FormatRecallResults(results []RecallResult) string {
+	if len(results) == 0 {
+		return "No similar messages found."
+	}
+	var b strings.Builder
+	for i, r := range results {
+		text := r.Message.Text
+		if len([]rune(text)) > 200 {
+			text = string([]rune(text)[:200]) + "…"
+		}
+		fmt.Fprintf(&b, "%d. %s, %s (%.0f%%)\n%s\n", i+1, r.Message.Username,
+			r.Message.Created.Local().Format("2006-01-02 15:04"), r.Score*100, text)
+		if link := MessageLink(r.Message.ChatID, r.Message.MessageID); link != "" {
+			fmt.Fprintf(&b, "%s\n", link)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// MessageLink returns a t.me link to a message, or "" when Telegram offers no
+// link for the chat (private chats and basic groups).
+func MessageLink(chatID, messageID int64) string {
+	if messageID == 0 || chatID > -1000000000000 {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/c/%d/%d", -chatID-1000000000000, messageID)
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return v
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
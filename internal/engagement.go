@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Engagement modes control how chatty the bot is in a chat.
+const (
+	ModeActive       = "active"        // commands, mentions and unsolicited smart replies
+	ModeMentionOnly  = "mention-only"  // commands and messages addressed to the bot
+	ModeCommandsOnly = "commands-only" // explicit commands and /ai only
+	ModeSilent       = "silent"        // nothing except /mode, /forgetme and /stop
+)
+
+// EngagementModes lists the valid modes.
+var EngagementModes = []string{ModeActive, ModeMentionOnly, ModeCommandsOnly, ModeSilent}
+
+var modeAliases = map[string]string{
+	"mention":  ModeMentionOnly,
+	"mentions": ModeMentionOnly,
+	"commands": ModeCommandsOnly,
+	"command":  ModeCommandsOnly,
+	"quiet":    ModeSilent,
+	"off":      ModeSilent,
+	"on":       ModeActive,
+}
+
+// ChatSettings are the per-chat engagement settings stored in chat_settings.
+type ChatSettings struct {
+	ChatID          int64
+	Mode            string
+	CooldownSeconds int    // minimum gap between unsolicited replies
+	QuietHours      string // "HH:MM-HH:MM" in server local time, empty for none
+}
+
+func initChatSettingsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS chat_settings (
+			chat_id INTEGER PRIMARY KEY,
+			mode TEXT,
+			cooldown_seconds INTEGER DEFAULT 0,
+			quiet_hours TEXT,
+			updated DATETIME
+		)
+	`)
+	return err
+}
+
+// GetChatSettings returns the stored settings for chatID, or the defaults
+// (active, no cooldown, no quiet hours) if none are stored.
+func GetChatSettings(chatID int64) ChatSettings {
+	s := ChatSettings{ChatID: chatID, Mode: ModeActive}
+	if DB == nil {
+		return s
+	}
+	var mode, quiet sql.NullString
+	err := DB.QueryRow(`SELECT mode, COALESCE(cooldown_seconds, 0), quiet_hours FROM chat_settings WHERE chat_id = ?`, chatID).
+		Scan(&mode, &s.CooldownSeconds, &quiet)
+	if err != nil {
+		return s
+	}
+	if mode.Valid && mode.String != "" {
+		s.Mode = mode.String
+	}
+	s.QuietHours = quiet.String
+	return s
+}
+
+// SaveChatSettings stores the engagement settings for s.ChatID.
+func SaveChatSettings(s ChatSettings) error {
+	_, err := DB.Exec(`
+		INSERT INTO chat_settings (chat_id, mode, cooldown_seconds, quiet_hours, updated) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET mode = excluded.mode, cooldown_seconds = excluded.cooldown_seconds,
+			quiet_hours = excluded.quiet_hours, updated = excluded.updated`,
+		s.ChatID, s.Mode, s.CooldownSeconds, s.QuietHours, time.Now().UTC())
+	return err
+}
+
+// ParseEngagementMode validates a mode name, accepting short aliases.
+func ParseEngagementMode(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if alias, ok := modeAliases[s]; ok {
+		return alias, nil
+	}
+	for _, m := range EngagementModes {
+		if m == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown mode %q; use one of %s", s, strings.Join(EngagementModes, ", "))
+}
+
+// ParseQuietHours validates a "HH:MM-HH:MM" range. "off" or "" clears it.
+func ParseQuietHours(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "off") || strings.EqualFold(s, "none") {
+		return "", nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return "", fmt.Errorf("quiet hours must look like 22:00-07:00")
+	}
+	for _, part := range []string{from, to} {
+		if _, err := time.Parse("15:04", strings.TrimSpace(part)); err != nil {
+			return "", fmt.Errorf("invalid time %q, expected HH:MM", part)
+		}
+	}
+	return strings.TrimSpace(from) + "-" + strings.TrimSpace(to), nil
+}
+
+// InQuietHours reports whether now falls inside the settings' quiet hours.
+// Ranges that cross midnight (22:00-07:00) are supported.
+func (s ChatSettings) InQuietHours(now time.Time) bool {
+	from, to, ok := strings.Cut(s.QuietHours, "-")
+	if !ok {
+		return false
+	}
+	start, err1 := time.Parse("15:04", from)
+	end, err2 := time.Parse("15:04", to)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	minute := func(t time.Time) int { return t.Hour()*60 + t.Minute() }
+	cur, a, b := minute(now), minute(start), minute(end)
+	if a <= b {
+		return cur >= a && cur < b
+	}
+	return cur >= a || cur < b
+}
+
+var (
+	lastSmartReplyMu sync.Mutex
+	lastSmartReply   = map[int64]time.Time{}
+)
+
+// allowUnsolicitedReply applies quiet hours and the per-chat cooldown to
+// replies the bot was not explicitly asked for.
+func allowUnsolicitedReply(s ChatSettings, now time.Time) bool {
+	if s.InQuietHours(now) {
+		return false
+	}
+	if s.CooldownSeconds <= 0 {
+		return true
+	}
+	lastSmartReplyMu.Lock()
+	defer lastSmartReplyMu.Unlock()
+	return now.Sub(lastSmartReply[s.ChatID]) >= time.Duration(s.CooldownSeconds)*time.Second
+}
+
+// markSmartReply records that the bot just replied unprompted in chatID.
+func markSmartReply(chatID int64, now time.Time) {
+	lastSmartReplyMu.Lock()
+	lastSmartReply[chatID] = now
+	lastSmartReplyMu.Unlock()
+}
+
+// FormatChatSettings describes the engagement settings for /mode.
+func FormatChatSettings(s ChatSettings) string {
+	cooldown := "none"
+	if s.CooldownSeconds > 0 {
+		cooldown = (time.Duration(s.CooldownSeconds) * time.Second).String()
+	}
+	quiet := "none"
+	if s.QuietHours != "" {
+		quiet = s.QuietHours + " (server time)"
+	}
+	return fmt.Sprintf("Mode: %s\nReply cooldown: %s\nQuiet hours: %s", s.Mode, cooldown, quiet)
+}
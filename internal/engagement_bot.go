@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"log"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const modeUsage = `Usage:
+/mode — show this chat's settings
+/mode active|mention-only|commands-only|silent
+/mode cooldown <30s|5m|off>
+/mode quiet <22:00-07:00|off>`
+
+// handleModeCommand shows or changes how chatty the bot is in this chat.
+// Anyone may view the settings; only chat admins may change them, or anyone
+// in a private chat.
+func handleModeCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	settings := GetChatSettings(m.Chat.ID)
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	sub, value := splitFirstWord(args)
+	if sub == "" {
+		reply(FormatChatSettings(settings) + "\n\n" + modeUsage)
+		return
+	}
+	if !canManageChat(bot, m.Chat, m.From.ID) {
+		reply("⛔ Only chat admins can change the bot's mode.")
+		return
+	}
+	switch strings.ToLower(sub) {
+	case "cooldown":
+		if value == "" {
+			reply(modeUsage)
+			return
+		}
+		seconds := 0
+		if !strings.EqualFold(value, "off") {
+			d, err := ParseDurationArg(value)
+			if err != nil {
+				reply(err.Error())
+				return
+			}
+			seconds = int(d.Seconds())
+		}
+		settings.CooldownSeconds = seconds
+	case "quiet":
+		quiet, err := ParseQuietHours(value)
+		if err != nil {
+			reply(err.Error())
+			return
+		}
+		settings.QuietHours = quiet
+	default:
+		mode, err := ParseEngagementMode(sub)
+		if err != nil {
+			reply(err.Error() + "\n\n" + modeUsage)
+			return
+		}
+		settings.Mode = mode
+	}
+	if err := SaveChatSettings(settings); err != nil {
+		reply("[Mode error] " + err.Error())
+		return
+	}
+	log.Printf("[MODE] chat %d: %s by %d", m.Chat.ID, strings.TrimSpace(args), m.From.ID)
+	reply("✅ Updated\n" + FormatChatSettings(settings))
+}
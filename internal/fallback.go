@@ -0,0 +1,363 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAIUnavailable is returned when every AI backend failed or is being
+// skipped by its circuit breaker.
+var ErrAIUnavailable = errors.New("AI is temporarily unavailable")
+
+// DegradedMessage is shown to chat users instead of a raw AI error while the
+// bot runs without AI.
+const DegradedMessage = "⚠️ AI is temporarily unavailable, so I can only give canned replies for now. I'll switch back automatically once it recovers."
+
+const (
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// Circuit breaker states.
+const (
+	breakerClosed   = "closed"    // healthy, requests go through
+	breakerOpen     = "open"      // failing, requests are skipped until the cooldown ends
+	breakerHalfOpen = "half-open" // one probe request is allowed through
+)
+
+// circuitBreaker tracks consecutive failures of one backend.
+type circuitBreaker struct {
+	mu          sync.Mutex
+	state       string
+	failures    int
+	retryAt     time.Time
+	probing     bool
+	lastErr     string
+	lastSuccess time.Time
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*circuitBreaker{}
+)
+
+func (b AIBackend) baseURL() string {
+	if b.URL == "" {
+		return ollamaBaseURL
+	}
+	return strings.TrimRight(b.URL, "/")
+}
+
+func (b AIBackend) String() string {
+	if b.URL == "" {
+		return b.Model
+	}
+	return b.Model + " @ " + b.URL
+}
+
+func breakerFor(b AIBackend) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	key := b.String()
+	cb, ok := breakers[key]
+	if !ok {
+		cb = &circuitBreaker{state: breakerClosed}
+		breakers[key] = cb
+	}
+	return cb
+}
+
+// allow reports whether a request may be sent. Once an open breaker's
+// cooldown has passed, exactly one probe request is let through.
+func (cb *circuitBreaker) allow(now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case breakerOpen:
+		if now.Before(cb.retryAt) {
+			return false
+		}
+		cb.state, cb.probing = breakerHalfOpen, true
+		return true
+	case breakerHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	}
+	return true
+}
+
+func (cb *circuitBreaker) success(now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state != breakerClosed {
+		log.Printf("[AI] backend recovered")
+	}
+	cb.state, cb.failures, cb.probing, cb.lastSuccess = breakerClosed, 0, false, now
+}
+
+func (cb *circuitBreaker) failure(err error, now time.Time, threshold int, cooldown time.Duration) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	cb.lastErr = err.Error()
+	cb.probing = false
+	if cb.state == breakerHalfOpen || cb.failures >= threshold {
+		cb.state = breakerOpen
+		cb.retryAt = now.Add(cooldown)
+	}
+}
+
+func breakerSettings() (int, time.Duration) {
+	cfg := currentConfig().AIBreaker
+	threshold, cooldown := cfg.FailureThreshold, time.Duration(cfg.CooldownSeconds)*time.Second
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return threshold, cooldown
+}
+
+// aiBackends returns the requested model on the local Ollama followed by the
+// configured fallbacks, without duplicates. Requests with images only fall
+// back to the vision fallbacks: a text model would ignore the image and
+// answer anyway.
+func aiBackends(model string, vision bool) []AIBackend {
+	backends := []AIBackend{{Model: model}}
+	seen := map[string]bool{backends[0].String(): true}
+	fallbacks := currentConfig().AIFallbacks
+	if vision {
+		fallbacks = currentConfig().AIVisionFallbacks
+	}
+	for _, b := range fallbacks {
+		if b.Model == "" || seen[b.String()] {
+			continue
+		}
+		seen[b.String()] = true
+		backends = append(backends, b)
+	}
+	return backends
+}
+
+// GenerateWithFallback waits for a slot in the AI queue, then sends r to
+// r.Model and, if that fails, to each configured fallback in turn. Backends
+// whose circuit breaker is open are skipped without a request, so an outage
+// fails fast instead of queueing. When nothing answers the error wraps
+// ErrAIUnavailable.
+//
+// Calls whose context carries an AITask are checked against the daily quotas
+// first and recorded in ai_requests afterwards.
+func GenerateWithFallback(ctx context.Context, r OllamaRequest) (*OllamaResponse, error) {
+	task, tracked := ctx.Value(aiTaskKey{}).(AITask)
+	if tracked {
+		if err := checkAIQuota(task); err != nil {
+			return nil, err
+		}
+	}
+	record := func(started time.Time, resp *OllamaResponse, err error) {
+		if !tracked {
+			return
+		}
+		model := r.Model
+		if resp != nil {
+			model = resp.Model
+		}
+		RecordAIRequest(task.ChatID, task.UserID, task.Kind, model, started, usageOf(resp), err)
+	}
+	if !backendsAvailable(r.Model, len(r.Images) > 0) {
+		record(time.Now(), nil, ErrAIUnavailable)
+		return nil, ErrAIUnavailable
+	}
+	ctx, release, err := defaultAIQueue.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	started := time.Now()
+	resp, err := tryBackends(ctx, r)
+	record(started, resp, err)
+	return resp, err
+}
+
+// tryBackends sends r to each backend whose breaker allows it until one
+// answers.
+func tryBackends(ctx context.Context, r OllamaRequest) (*OllamaResponse, error) {
+	threshold, cooldown := breakerSettings()
+	var lastErr error
+	for _, b := range aiBackends(r.Model, len(r.Images) > 0) {
+		cb := breakerFor(b)
+		if !cb.allow(time.Now()) {
+			continue
+		}
+		req := r
+		req.Model = b.Model
+		resp, err := ollamaGenerateAt(ctx, b.baseURL(), req)
+		if err == nil {
+			cb.success(time.Now())
+			if resp.Model == "" {
+				resp.Model = b.Model
+			}
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			// Cancelled or timed out by the caller: not the backend's fault.
+			cb.mu.Lock()
+			cb.probing = false
+			cb.mu.Unlock()
+			return nil, err
+		}
+		log.Printf("[AI] %s failed: %v", b, err)
+		cb.failure(err, time.Now(), threshold, cooldown)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return nil, ErrAIUnavailable
+	}
+	return nil, fmt.Errorf("%w: %v", ErrAIUnavailable, lastErr)
+}
+
+// StartAIHealthProber checks open backends' hosts in the background and lets
+// the next request probe a backend as soon as its host answers again.
+func StartAIHealthProber(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	client := &http.Client{Timeout: 5 * time.Second}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, b := range knownBackends() {
+			cb := breakerFor(b)
+			cb.mu.Lock()
+			open := cb.state == breakerOpen
+			cb.mu.Unlock()
+			if !open {
+				continue
+			}
+			resp, err := client.Get(b.baseURL() + "/api/tags")
+			if err != nil {
+				continue
+			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				cb.mu.Lock()
+				cb.retryAt = time.Now()
+				cb.mu.Unlock()
+			}
+		}
+	}
+}
+
+// AIAvailable reports whether at least one backend for the default model is
+// currently usable.
+func AIAvailable() bool {
+	return backendsAvailable(currentConfig().AIModel(), false)
+}
+
+// knownBackends returns the default and vision models with their fallbacks.
+func knownBackends() []AIBackend {
+	cfg := currentConfig()
+	backends := aiBackends(cfg.AIModel(), false)
+	for _, b := range aiBackends(cfg.AIVisionModel(), true) {
+		if !containsBackend(backends, b) {
+			backends = append(backends, b)
+		}
+	}
+	return backends
+}
+
+func containsBackend(list []AIBackend, b AIBackend) bool {
+	for _, v := range list {
+		if v.String() == b.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func backendsAvailable(model string, vision bool) bool {
+	now := time.Now()
+	for _, b := range aiBackends(model, vision) {
+		cb := breakerFor(b)
+		cb.mu.Lock()
+		ok := cb.state != breakerOpen || !now.Before(cb.retryAt)
+		cb.mu.Unlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatAIStatus describes each backend's circuit breaker for /status.
+func FormatAIStatus() string {
+	breakersMu.Lock()
+	keys := make([]string, 0, len(breakers))
+	for k := range breakers {
+		keys = append(keys, k)
+	}
+	breakersMu.Unlock()
+	for _, b := range knownBackends() {
+		if !contains(keys, b.String()) {
+			keys = append(keys, b.String())
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	if AIAvailable() {
+		b.WriteString("AI: ✅ available")
+	} else {
+		b.WriteString("AI: ⚠️ degraded, using canned replies")
+	}
+	now := time.Now()
+	for _, key := range keys {
+		breakersMu.Lock()
+		cb := breakers[key]
+		breakersMu.Unlock()
+		if cb == nil {
+			fmt.Fprintf(&b, "\n• %s: not used yet", key)
+			continue
+		}
+		cb.mu.Lock()
+		switch cb.state {
+		case breakerOpen:
+			wait := cb.retryAt.Sub(now).Round(time.Second)
+			if wait < 0 {
+				wait = 0
+			}
+			fmt.Fprintf(&b, "\n• %s: open, %d failures, next probe in %s (%s)", key, cb.failures, wait, cb.lastErr)
+		case breakerHalfOpen:
+			fmt.Fprintf(&b, "\n• %s: probing for recovery", key)
+		default:
+			fmt.Fprintf(&b, "\n• %s: ok", key)
+			if cb.failures > 0 {
+				fmt.Fprintf(&b, " (%d recent failures)", cb.failures)
+			}
+		}
+		cb.mu.Unlock()
+	}
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
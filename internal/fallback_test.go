@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerCycle(t *testing.T) {
+	const threshold, cooldown = 2, 30 * time.Second
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		name      string
+		at        time.Duration
+		op        string // allow, fail or ok
+		wantAllow bool   // for allow
+		wantState string
+	}{
+		{"healthy backend is used", 0, "allow", true, breakerClosed},
+		{"one failure is tolerated", 0, "fail", false, breakerClosed},
+		{"threshold opens the circuit", time.Second, "fail", false, breakerOpen},
+		{"open circuit skips the backend", 10 * time.Second, "allow", false, breakerOpen},
+		{"cooldown over: one probe", 31 * time.Second, "allow", true, breakerHalfOpen},
+		{"only one probe at a time", 31 * time.Second, "allow", false, breakerHalfOpen},
+		{"failed probe reopens", 32 * time.Second, "fail", false, breakerOpen},
+		{"new cooldown applies", 50 * time.Second, "allow", false, breakerOpen},
+		{"second probe", 62 * time.Second, "allow", true, breakerHalfOpen},
+		{"successful probe closes", 63 * time.Second, "ok", false, breakerClosed},
+		{"closed again", 63 * time.Second, "allow", true, breakerClosed},
+		{"failures were reset", 64 * time.Second, "fail", false, breakerClosed},
+	}
+	cb := &circuitBreaker{state: breakerClosed}
+	for _, s := range steps {
+		now := start.Add(s.at)
+		switch s.op {
+		case "allow":
+			if got := cb.allow(now); got != s.wantAllow {
+				t.Errorf("%s: allow = %v, want %v", s.name, got, s.wantAllow)
+			}
+		case "fail":
+			cb.failure(errors.New("connection refused"), now, threshold, cooldown)
+		case "ok":
+			cb.success(now)
+		}
+		if cb.state != s.wantState {
+			t.Fatalf("%s: state = %s, want %s", s.name, cb.state, s.wantState)
+		}
+	}
+}
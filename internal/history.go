@@ -0,0 +1,356 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MessageFilter narrows down which stored messages are returned by QueryMessages.
+// Zero values mean "no restriction".
+type MessageFilter struct {
+	ChatID int64
+	UserID int64
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// QueryMessages returns stored messages matching the filter, oldest first.
+func QueryMessages(f MessageFilter) ([]Message, error) {
+	where := []string{"1=1"}
+	args := []interface{}{}
+	if f.ChatID != 0 {
+		where = append(where, "chat_id = ?")
+		args = append(args, f.ChatID)
+	}
+	if f.UserID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created < ?")
+		args = append(args, f.Until.UTC())
+	}
+	query := `SELECT id, chat_id, COALESCE(message_id, 0), user_id, COALESCE(username, ''), COALESCE(text, ''), is_bot, COALESCE(type, ''), COALESCE(media, ''), created
+		FROM messages WHERE ` + strings.Join(where, " AND ")
+	if f.Limit > 0 {
+		// Keep the most recent N messages but still return them oldest first.
+		query = `SELECT * FROM (` + query + fmt.Sprintf(` ORDER BY created DESC, id DESC LIMIT %d)`, f.Limit)
+	}
+	query += ` ORDER BY created, id`
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var msgs []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.MessageID, &m.UserID, &m.Username, &m.Text, &m.IsBot, &m.Type, &m.Media, &m.Created); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// ParseTimeArg parses a point in time given on the command line or in a chat
+// command. It accepts RFC3339, "2006-01-02", "2006-01-02 15:04", or a relative
+// duration such as "90m", "24h", "7d" or "2w" meaning that long ago.
+func ParseTimeArg(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	d, err := ParseDurationArg(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use YYYY-MM-DD, RFC3339 or a duration like 24h/7d", s)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// ParseDurationArg extends time.ParseDuration with day ("d") and week ("w") units.
+func ParseDurationArg(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if n := len(s); n > 1 && (s[n-1] == 'd' || s[n-1] == 'w') {
+		v, err := strconv.Atoi(s[:n-1])
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		unit := 24 * time.Hour
+		if s[n-1] == 'w' {
+			unit *= 7
+		}
+		return time.Duration(v) * unit, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ExportFormats lists the formats supported by ExportMessages.
+var ExportFormats = []string{"json", "jsonl", "csv", "markdown"}
+
+type exportedMessage struct {
+	ID        int64     `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	MessageID int64     `json:"message_id,omitempty"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Text      string    `json:"text"`
+	IsBot     bool      `json:"is_bot"`
+	Type      string    `json:"type"`
+	Media     string    `json:"media,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+func toExported(m Message) exportedMessage {
+	return exportedMessage{m.ID, m.ChatID, m.MessageID, m.UserID, m.Username, m.Text, m.IsBot, m.Type, m.Media, m.Created}
+}
+
+// ExportMessages writes messages to w in one of ExportFormats.
+func ExportMessages(w io.Writer, msgs []Message, format string) error {
+	switch strings.ToLower(format) {
+	case "json":
+		out := make([]exportedMessage, 0, len(msgs))
+		for _, m := range msgs {
+			out = append(out, toExported(m))
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, m := range msgs {
+			if err := enc.Encode(toExported(m)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "chat_id", "message_id", "user_id", "username", "is_bot", "type", "created", "text", "media"})
+		for _, m := range msgs {
+			cw.Write([]string{
+				strconv.FormatInt(m.ID, 10),
+				strconv.FormatInt(m.ChatID, 10),
+				strconv.FormatInt(m.MessageID, 10),
+				strconv.FormatInt(m.UserID, 10),
+				m.Username,
+				strconv.FormatBool(m.IsBot),
+				m.Type,
+				m.Created.Format(time.RFC3339),
+				m.Text,
+				m.Media,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "markdown", "md":
+		var lastChat int64
+		for i, m := range msgs {
+			if i == 0 || m.ChatID != lastChat {
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintf(w, "# Chat %d\n\n", m.ChatID)
+				lastChat = m.ChatID
+			}
+			text := strings.ReplaceAll(m.Text, "\n", "\n  ")
+			if kind, _, ok := strings.Cut(m.Media, ":"); ok {
+				text = strings.TrimSpace("[" + kind + "] " + text)
+			}
+			fmt.Fprintf(w, "- **%s** _%s_ `%s`: %s\n", m.Username, m.Created.Local().Format("2006-01-02 15:04"), m.Type, text)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(ExportFormats, ", "))
+	}
+}
+
+// telegramExportChat mirrors a single chat in a Telegram Desktop "Export chat
+// history" result.json (machine-readable JSON format).
+type telegramExportChat struct {
+	Name     string                  `json:"name"`
+	Type     string                  `json:"type"`
+	ID       int64                   `json:"id"`
+	Messages []telegramExportMessage `json:"messages"`
+}
+
+type telegramExportMessage struct {
+	ID           int64           `json:"id"`
+	Type         string          `json:"type"`
+	Date         string          `json:"date"`
+	DateUnixtime string          `json:"date_unixtime"`
+	From         string          `json:"from"`
+	FromID       string          `json:"from_id"`
+	Text         json.RawMessage `json:"text"`
+}
+
+// ImportResult summarises a Telegram Desktop import.
+type ImportResult struct {
+	Chats    int
+	Imported int
+	Skipped  int
+}
+
+// ImportTelegramExport ingests a Telegram Desktop JSON export into the messages
+// table. Both single-chat exports and full account exports ("chats.list") are
+// accepted. When chatID is non-zero every imported message is assigned to it,
+// otherwise the Bot API chat ID is derived from the exported chat. Messages
+// already present (same chat and Telegram message ID) are skipped.
+func ImportTelegramExport(r io.Reader, chatID int64) (ImportResult, error) {
+	var res ImportResult
+	var doc struct {
+		telegramExportChat
+		Chats struct {
+			List []telegramExportChat `json:"list"`
+		} `json:"chats"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return res, fmt.Errorf("decode export: %w", err)
+	}
+	chats := doc.Chats.List
+	if len(doc.Messages) > 0 {
+		chats = append(chats, doc.telegramExportChat)
+	}
+	if len(chats) == 0 {
+		return res, fmt.Errorf("no chats found in export")
+	}
+	for _, c := range chats {
+		target := chatID
+		if target == 0 {
+			target = botAPIChatID(c.Type, c.ID)
+		}
+		if target == 0 {
+			res.Skipped += len(c.Messages)
+			continue
+		}
+		res.Chats++
+		tx, err := DB.Begin()
+		if err != nil {
+			return res, err
+		}
+		for _, em := range c.Messages {
+			text := telegramExportText(em.Text)
+			if em.Type != "message" || strings.TrimSpace(text) == "" {
+				res.Skipped++
+				continue
+			}
+			var exists int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM messages WHERE chat_id = ? AND message_id = ?`, target, em.ID).Scan(&exists); err != nil {
+				tx.Rollback()
+				return res, err
+			}
+			if exists > 0 {
+				res.Skipped++
+				continue
+			}
+			_, err := tx.Exec(`
+				INSERT INTO messages (chat_id, message_id, user_id, username, text, is_bot, type, created)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				target, em.ID, telegramExportSender(em.FromID), em.From, text, false, importLabel(text), telegramExportDate(em).UTC())
+			if err != nil {
+				tx.Rollback()
+				return res, err
+			}
+			res.Imported++
+		}
+		if err := tx.Commit(); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// botAPIChatID converts the bare ID used in Telegram Desktop exports to the
+// signed ID the Bot API reports in update.Message.Chat.ID.
+func botAPIChatID(chatType string, id int64) int64 {
+	switch chatType {
+	case "private_supergroup", "public_supergroup", "private_channel", "public_channel":
+		return -(1000000000000 + id)
+	case "private_group":
+		return -id
+	case "personal_chat", "bot_chat", "saved_messages":
+		return id
+	default:
+		return 0
+	}
+}
+
+// telegramExportText flattens the export's text field, which is either a plain
+// string or an array mixing strings and {"type": ..., "text": ...} entities.
+func telegramExportText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		var ps string
+		if err := json.Unmarshal(p, &ps); err == nil {
+			b.WriteString(ps)
+			continue
+		}
+		var entity struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(p, &entity); err == nil {
+			b.WriteString(entity.Text)
+		}
+	}
+	return b.String()
+}
+
+// telegramExportSender parses from_id values such as "user123" or "channel456".
+func telegramExportSender(fromID string) int64 {
+	for _, prefix := range []string{"user", "channel", "chat"} {
+		if strings.HasPrefix(fromID, prefix) {
+			id, _ := strconv.ParseInt(strings.TrimPrefix(fromID, prefix), 10, 64)
+			if prefix == "channel" {
+				id = -(1000000000000 + id)
+			}
+			return id
+		}
+	}
+	return 0
+}
+
+func telegramExportDate(em telegramExportMessage) time.Time {
+	if em.DateUnixtime != "" {
+		if sec, err := strconv.ParseInt(em.DateUnixtime, 10, 64); err == nil {
+			return time.Unix(sec, 0)
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", em.Date, time.Local); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// importLabel classifies imported messages with the rules classifier only, so
+// bulk imports don't issue an AI call per message.
+func importLabel(text string) string {
+	res, _ := RulesClassifier{}.Classify(context.Background(), text)
+	return res.Label
+}
@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	chunkLines       = 40
+	chunkOverlap     = 10
+	maxIndexedFile   = 512 * 1024
+	knowledgeResults = 5
+)
+
+// defaultKnowledgeExts are indexed when a source has no Include globs.
+var defaultKnowledgeExts = map[string]bool{
+	".go": true, ".md": true, ".txt": true, ".rst": true, ".json": true, ".yaml": true, ".yml": true,
+	".toml": true, ".py": true, ".js": true, ".ts": true, ".tsx": true, ".jsx": true, ".rs": true,
+	".java": true, ".kt": true, ".c": true, ".h": true, ".cpp": true, ".hpp": true, ".cs": true,
+	".rb": true, ".php": true, ".sh": true, ".sql": true, ".proto": true, ".html": true, ".css": true,
+}
+
+// skippedKnowledgeDirs are never descended into while indexing.
+var skippedKnowledgeDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true,
+	"target": true, ".venv": true, "__pycache__": true, ".idea": true,
+}
+
+// KnowledgeChunk is a line range of an indexed file.
+type KnowledgeChunk struct {
+	Source    string
+	Path      string
+	StartLine int
+	EndLine   int
+	Content   string
+	Score     float64
+}
+
+// Citation formats the chunk location as source/path:Lstart-end.
+func (c KnowledgeChunk) Citation() string {
+	return fmt.Sprintf("%s/%s:L%d-%d", c.Source, c.Path, c.StartLine, c.EndLine)
+}
+
+// KnowledgeIndexStats summarises an index rebuild for one source.
+type KnowledgeIndexStats struct {
+	Source string
+	Files  int
+	Chunks int
+}
+
+func initKnowledgeTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS doc_chunks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT,
+			path TEXT,
+			start_line INTEGER,
+			end_line INTEGER,
+			content TEXT,
+			model TEXT,
+			vector BLOB,
+			created DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_doc_chunks_source ON doc_chunks (source)`)
+	return err
+}
+
+// RebuildKnowledgeIndex re-chunks and re-embeds the named knowledge source, or
+// every configured source when name is empty. progress, if set, is called with
+// each file path as it is indexed.
+func RebuildKnowledgeIndex(ctx context.Context, name string, progress func(path string)) ([]KnowledgeIndexStats, error) {
+	cfg := currentConfig()
+	var stats []KnowledgeIndexStats
+	found := false
+	for _, src := range cfg.KnowledgeSources {
+		if name != "" && src.Name != name {
+			continue
+		}
+		found = true
+		st, err := indexKnowledgeSource(ctx, src, cfg.EmbeddingModel, progress)
+		stats = append(stats, st)
+		if err != nil {
+			return stats, fmt.Errorf("%s: %w", src.Name, err)
+		}
+	}
+	if name != "" && !found {
+		return nil, fmt.Errorf("no knowledge source named %q", name)
+	}
+	return stats, nil
+}
+
+func indexKnowledgeSource(ctx context.Context, src KnowledgeSource, model string, progress func(string)) (KnowledgeIndexStats, error) {
+	st := KnowledgeIndexStats{Source: src.Name}
+	root, err := filepath.Abs(src.Path)
+	if err != nil {
+		return st, err
+	}
+	if _, err := os.Stat(root); err != nil {
+		return st, err
+	}
+	// Embed everything before touching the table so a failed rebuild keeps the
+	// old index and the database isn't locked while Ollama works.
+	type embedded struct {
+		chunk  KnowledgeChunk
+		vector []byte
+	}
+	var pending []embedded
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (skippedKnowledgeDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !includeKnowledgeFile(src, d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() == 0 || info.Size() > maxIndexedFile {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+			return nil // unreadable or binary
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if progress != nil {
+			progress(rel)
+		}
+		for _, c := range chunkFile(string(data)) {
+			vec, err := OllamaEmbed(ctx, rel+"\n"+c.Content, model)
+			if err != nil {
+				return err
+			}
+			c.Source, c.Path = src.Name, rel
+			pending = append(pending, embedded{c, encodeVector(vec)})
+		}
+		st.Files++
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return st, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM doc_chunks WHERE source = ?`, src.Name); err != nil {
+		return st, err
+	}
+	for _, p := range pending {
+		_, err = tx.Exec(`INSERT INTO doc_chunks (source, path, start_line, end_line, content, model, vector) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			p.chunk.Source, p.chunk.Path, p.chunk.StartLine, p.chunk.EndLine, p.chunk.Content, model, p.vector)
+		if err != nil {
+			return st, err
+		}
+		st.Chunks++
+	}
+	return st, tx.Commit()
+}
+
+func includeKnowledgeFile(src KnowledgeSource, name string) bool {
+	if len(src.Include) == 0 {
+		return defaultKnowledgeExts[strings.ToLower(filepath.Ext(name))]
+	}
+	for _, pattern := range src.Include {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// chunkFile splits text into overlapping windows of chunkLines lines.
+func chunkFile(text string) []KnowledgeChunk {
+	lines := strings.Split(text, "\n")
+	var chunks []KnowledgeChunk
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		content := strings.TrimSpace(strings.Join(lines[start:end], "\n"))
+		if content != "" {
+			chunks = append(chunks, KnowledgeChunk{StartLine: start + 1, EndLine: end, Content: content})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// SearchKnowledge returns the indexed chunks most similar to question. ctx may
+// carry the caller's AITask.
+func SearchKnowledge(ctx context.Context, question string, limit int) ([]KnowledgeChunk, error) {
+	model := currentConfig().EmbeddingModel
+	qvec, err := OllamaEmbed(ctx, question, model)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := DB.Query(`SELECT source, path, start_line, end_line, content, vector FROM doc_chunks WHERE model = ?`, model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var chunks []KnowledgeChunk
+	for rows.Next() {
+		var c KnowledgeChunk
+		var blob []byte
+		if err := rows.Scan(&c.Source, &c.Path, &c.StartLine, &c.EndLine, &c.Content, &blob); err != nil {
+			return nil, err
+		}
+		c.Score = cosineSimilarity(qvec, decodeVector(blob))
+		chunks = append(chunks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Score > chunks[j].Score })
+	if len(chunks) > limit {
+		chunks = chunks[:limit]
+	}
+	return chunks, nil
+}
+
+// buildKnowledgePrompt places the retrieved chunks ahead of the question and
+// asks the model to cite them by their bracketed location.
+func buildKnowledgePrompt(question string, chunks []KnowledgeChunk) string {
+	var b strings.Builder
+	b.WriteString("You are a software engineering assistant answering questions about the team's own code and documentation. ")
+	b.WriteString("Use only the excerpts below. Cite the excerpts you rely on by their location in square brackets, e.g. [repo/main.go:L10-50]. ")
+	b.WriteString("If the excerpts do not contain the answer, say so.\n\n")
+	for _, c := range chunks {
+		fmt.Fprintf(&b, "[%s]\n%s\n\n", c.Citation(), c.Content)
+	}
+	fmt.Fprintf(&b, "Question: %s", question)
+	return b.String()
+}
+
+// AnswerFromKnowledge retrieves relevant chunks for question and asks model to
+// answer from them. The answer ends with the list of cited sources.
+func AnswerFromKnowledge(ctx context.Context, question, model string) (string, error) {
+	chunks, err := SearchKnowledge(ctx, question, knowledgeResults)
+	if err != nil {
+		return "", err
+	}
+	if len(chunks) == 0 {
+		return "", fmt.Errorf("the knowledge index is empty; run `ideasbglobot index rebuild` first")
+	}
+	// The same question over the same sources gets the same answer in any chat.
+	key := CacheKey{Question: question}
+	for _, c := range chunks {
+		key.Context = append(key.Context, c.Citation(), c.Content)
+	}
+	resp, _, err := CachedGenerate(ctx, OllamaRequest{Model: model, Prompt: buildKnowledgePrompt(question, chunks)}, key)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(resp.Response))
+	b.WriteString("\n\nSources:")
+	for _, c := range chunks {
+		fmt.Fprintf(&b, "\n• %s", c.Citation())
+	}
+	return b.String(), nil
+}
+
+// DeleteKnowledgeSource drops all indexed chunks of a source.
+func DeleteKnowledgeSource(name string) error {
+	_, err := DB.Exec(`DELETE FROM doc_chunks WHERE source = ?`, name)
+	return err
+}
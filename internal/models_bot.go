@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// modelCallbackPrefix marks /models buttons: "mdl:<model name>" selects the
+// model for the chat.
+const modelCallbackPrefix = "mdl:"
+
+const modelUsage = `Usage:
+/models — list installed models and pick one for this chat
+/model info [name] — details of a model (default: this chat's model)
+/model pull <name> — download a model (bot admins)`
+
+// handleModelsCommand lists installed Ollama models as selectable buttons.
+func handleModelsCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message) {
+	models, err := ListOllamaModels()
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "[Models error] "+err.Error()))
+		return
+	}
+	if len(models) == 0 {
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, "No Ollama models installed. A bot admin can run /model pull <name>."))
+		return
+	}
+	current := ResolveAISettings(m.Chat.ID, 0).Model
+	reply := tgbotapi.NewMessage(m.Chat.ID, formatModelList(models, current))
+	reply.ReplyMarkup = modelsKeyboard(models, current)
+	bot.Send(reply)
+}
+
+// handleModelCommand answers /model info and /model pull.
+func handleModelCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	sub, name := splitFirstWord(args)
+	switch strings.ToLower(sub) {
+	case "info", "show":
+		if name == "" {
+			name = ResolveAISettings(m.Chat.ID, m.From.ID).Model
+		}
+		text, err := describeModel(name)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(m.Chat.ID, "[Model error] "+err.Error()))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, text))
+	case "pull":
+		if !currentConfig().IsAdmin(m.From.ID) {
+			bot.Send(tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("⛔ Only bot admins can pull models. Add %d to \"admins\" in configs.json.", m.From.ID)))
+			return
+		}
+		if name == "" {
+			bot.Send(tgbotapi.NewMessage(m.Chat.ID, modelUsage))
+			return
+		}
+		go pullModelWithProgress(bot, m.Chat.ID, name)
+	default:
+		current := ResolveAISettings(m.Chat.ID, m.From.ID).Model
+		bot.Send(tgbotapi.NewMessage(m.Chat.ID, fmt.Sprintf("Current model: %s\n\n%s", current, modelUsage)))
+	}
+}
+
+// handleModelCallback selects a model from the /models keyboard for the chat.
+func handleModelCallback(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
+	name := strings.TrimPrefix(cq.Data, modelCallbackPrefix)
+	if cq.Message == nil || name == "" {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Invalid model button"))
+		return
+	}
+	chat := cq.Message.Chat
+	if !canManageChat(bot, chat, cq.From.ID) {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "⛔ Only chat admins can change this chat's model"))
+		return
+	}
+	if err := SetAIOverride(ScopeChat, chat.ID, "model", name); err != nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "[Model error] "+err.Error()))
+		return
+	}
+	log.Printf("[MODEL] chat %d: %s by %d", chat.ID, name, cq.From.ID)
+	bot.Request(tgbotapi.NewCallback(cq.ID, "✅ Using "+name+" in this chat"))
+	if models, err := ListOllamaModels(); err == nil {
+		bot.Request(tgbotapi.NewEditMessageTextAndMarkup(chat.ID, cq.Message.MessageID, formatModelList(models, name), modelsKeyboard(models, name)))
+	}
+}
+
+func formatModelList(models []OllamaModel, current string) string {
+	var b strings.Builder
+	b.WriteString("🧠 Installed models (tap to use in this chat):\n")
+	for _, m := range models {
+		mark := "•"
+		if m.Name == current || m.Name == current+":latest" {
+			mark = "✅"
+		}
+		fmt.Fprintf(&b, "%s %s — %s", mark, m.Name, FormatBytes(m.Size))
+		if d := strings.TrimSpace(strings.Join([]string{m.Details.Family, m.Details.ParameterSize, m.Details.QuantizationLevel}, " ")); d != "" {
+			fmt.Fprintf(&b, ", %s", d)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func modelsKeyboard(models []OllamaModel, current string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, m := range models {
+		data := modelCallbackPrefix + m.Name
+		if len(data) > 64 { // Telegram's callback data limit
+			continue
+		}
+		text := m.Name
+		if m.Name == current || m.Name == current+":latest" {
+			text = "✅ " + text
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(text, data))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// describeModel formats /api/show details for a model.
+func describeModel(name string) (string, error) {
+	info, err := OllamaShow(name)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧠 %s\n", name)
+	if m, err := FindOllamaModel(name); err == nil {
+		fmt.Fprintf(&b, "Size: %s\nModified: %s\n", FormatBytes(m.Size), m.ModifiedAt.Local().Format("2006-01-02 15:04"))
+	}
+	d := info.Details
+	for _, f := range [][2]string{{"Family", d.Family}, {"Parameters", d.ParameterSize}, {"Quantization", d.QuantizationLevel}, {"Format", d.Format}} {
+		if f[1] != "" {
+			fmt.Fprintf(&b, "%s: %s\n", f[0], f[1])
+		}
+	}
+	if n := info.ContextLength(); n > 0 {
+		fmt.Fprintf(&b, "Context length: %d\n", n)
+	}
+	if info.License != "" {
+		first, _, _ := strings.Cut(strings.TrimSpace(info.License), "\n")
+		fmt.Fprintf(&b, "License: %s\n", first)
+	}
+	return b.String(), nil
+}
+
+// pullModelWithProgress pulls a model and keeps one chat message updated with
+// Ollama's streamed status, editing at most every few seconds.
+func pullModelWithProgress(bot *tgbotapi.BotAPI, chatID int64, name string) {
+	sent, err := bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⏬ Pulling %s…", name)))
+	if err != nil {
+		log.Printf("Error starting pull message: %v", err)
+		return
+	}
+	edit := func(text string) {
+		bot.Request(tgbotapi.NewEditMessageText(chatID, sent.MessageID, text))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+	var lastEdit time.Time
+	var lastText string
+	err = OllamaPull(ctx, name, func(p PullProgress) {
+		text := fmt.Sprintf("⏬ Pulling %s: %s", name, p.Status)
+		if pct := p.Percent(); pct >= 0 {
+			text += fmt.Sprintf(" %d%% (%s / %s)", pct, FormatBytes(p.Completed), FormatBytes(p.Total))
+		}
+		if text == lastText || time.Since(lastEdit) < 3*time.Second {
+			return
+		}
+		lastText, lastEdit = text, time.Now()
+		edit(text)
+	})
+	if err != nil {
+		edit(fmt.Sprintf("❌ Pull of %s failed: %v", name, err))
+		return
+	}
+	log.Printf("[MODEL] pulled %s", name)
+	edit(fmt.Sprintf("✅ Pulled %s. Select it with /models.", name))
+}
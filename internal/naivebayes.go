@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode"
+)
+
+// NaiveBayesModel is a multinomial naive Bayes text classifier trained on
+// labelled messages. It is stored as JSON in the app directory.
+type NaiveBayesModel struct {
+	TrainedAt   time.Time                 `json:"trained_at"`
+	Examples    int                       `json:"examples"`
+	ClassDocs   map[string]int            `json:"class_docs"`
+	TokenCounts map[string]map[string]int `json:"token_counts"`
+	ClassTokens map[string]int            `json:"class_tokens"`
+	Vocabulary  int                       `json:"vocabulary"`
+}
+
+// LabeledExample is a message text with its known label.
+type LabeledExample struct {
+	Text  string
+	Label string
+}
+
+// tokenize lowercases text into word tokens, keeping "?" as a token because
+// it is a strong signal for questions.
+func tokenize(text string) []string {
+	lower := strings.ToLower(text)
+	tokens := strings.FieldsFunc(lower, func(r rune) bool { return !isWordRune(r) })
+	out := tokens[:0]
+	for _, t := range tokens {
+		if len([]rune(t)) >= 2 && !unicode.IsDigit([]rune(t)[0]) {
+			out = append(out, t)
+		}
+	}
+	if strings.Contains(lower, "?") {
+		out = append(out, "?")
+	}
+	return out
+}
+
+// TrainNaiveBayes builds a model from labelled examples.
+func TrainNaiveBayes(examples []LabeledExample) *NaiveBayesModel {
+	m := &NaiveBayesModel{
+		TrainedAt:   time.Now(),
+		ClassDocs:   map[string]int{},
+		TokenCounts: map[string]map[string]int{},
+		ClassTokens: map[string]int{},
+	}
+	vocab := map[string]bool{}
+	for _, ex := range examples {
+		m.Examples++
+		m.ClassDocs[ex.Label]++
+		if m.TokenCounts[ex.Label] == nil {
+			m.TokenCounts[ex.Label] = map[string]int{}
+		}
+		for _, t := range tokenize(ex.Text) {
+			m.TokenCounts[ex.Label][t]++
+			m.ClassTokens[ex.Label]++
+			vocab[t] = true
+		}
+	}
+	m.Vocabulary = len(vocab)
+	return m
+}
+
+// Predict returns the most likely label and its posterior probability.
+func (m *NaiveBayesModel) Predict(text string) (string, float64) {
+	if m == nil || m.Examples == 0 {
+		return LabelMessage, 0
+	}
+	tokens := tokenize(text)
+	scores := map[string]float64{}
+	best, bestScore := "", math.Inf(-1)
+	for label, docs := range m.ClassDocs {
+		score := math.Log(float64(docs) / float64(m.Examples))
+		denom := float64(m.ClassTokens[label] + m.Vocabulary + 1)
+		for _, t := range tokens {
+			score += math.Log(float64(m.TokenCounts[label][t]+1) / denom)
+		}
+		scores[label] = score
+		if score > bestScore || (score == bestScore && label < best) {
+			best, bestScore = label, score
+		}
+	}
+	// Softmax over the log scores gives a confidence for the winning label.
+	var sum float64
+	for _, s := range scores {
+		sum += math.Exp(s - bestScore)
+	}
+	return best, 1 / sum
+}
+
+func localModelPath() string {
+	return filepath.Join(GetAppDir(), "classifier_model.json")
+}
+
+// SaveLocalModel writes the model to the app directory.
+func SaveLocalModel(m *NaiveBayesModel) error {
+	path := localModelPath()
+	tmp := path + ".tmp"
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+var (
+	localModelMu    sync.Mutex
+	localModel      *NaiveBayesModel
+	localModelMtime time.Time
+)
+
+// LoadLocalModel returns the trained model, reloading it when the file has
+// changed (e.g. after `classifier train`). It returns nil if none is trained.
+func LoadLocalModel() *NaiveBayesModel {
+	localModelMu.Lock()
+	defer localModelMu.Unlock()
+	info, err := os.Stat(localModelPath())
+	if err != nil {
+		localModel = nil
+		return nil
+	}
+	if localModel != nil && info.ModTime().Equal(localModelMtime) {
+		return localModel
+	}
+	data, err := os.ReadFile(localModelPath())
+	if err != nil {
+		return nil
+	}
+	var m NaiveBayesModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	localModel, localModelMtime = &m, info.ModTime()
+	return localModel
+}
+
+// LocalClassifier labels messages with the trained naive Bayes model.
+type LocalClassifier struct {
+	Model *NaiveBayesModel
+}
+
+func (c LocalClassifier) Classify(ctx context.Context, text string) (Classification, error) {
+	if c.Model == nil {
+		return Classification{}, fmt.Errorf("no local classifier trained; run `ideasbglobot classifier train`")
+	}
+	label, p := c.Model.Predict(text)
+	return Classification{Label: label, Confidence: p, Source: "local"}, nil
+}
+
+// LabeledMessages returns messages whose label was set by an admin and,
+// optionally, AI labels at or above minAIConfidence.
+func LabeledMessages(includeAI bool, minAIConfidence float64) ([]LabeledExample, error) {
+	query := `SELECT text, type FROM messages WHERE TRIM(COALESCE(text, '')) != '' AND (label_source = 'admin'`
+	args := []interface{}{}
+	if includeAI {
+		query += ` OR (label_source = 'ai' AND confidence >= ?)`
+		args = append(args, minAIConfidence)
+	}
+	query += `) ORDER BY id`
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []LabeledExample
+	for rows.Next() {
+		var ex LabeledExample
+		if err := rows.Scan(&ex.Text, &ex.Label); err != nil {
+			return nil, err
+		}
+		out = append(out, ex)
+	}
+	return out, rows.Err()
+}
+
+// SetMessageLabel records an admin's correction of a stored message's label.
+func SetMessageLabel(id int64, label string) error {
+	res, err := DB.Exec(`UPDATE messages SET type = ?, confidence = 1, label_source = 'admin' WHERE id = ?`, NormalizeLabel(label), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("message %d not found", id)
+	}
+	return nil
+}
+
+// LabelMetrics are evaluation results for one label.
+type LabelMetrics struct {
+	Label     string
+	Support   int
+	Precision float64
+	Recall    float64
+	F1        float64
+}
+
+// EvalReport summarises a cross-validated evaluation.
+type EvalReport struct {
+	Examples int
+	Folds    int
+	Accuracy float64
+	Labels   []LabelMetrics
+}
+
+// EvaluateNaiveBayes runs k-fold cross-validation over the examples.
+func EvaluateNaiveBayes(examples []LabeledExample, folds int) (*EvalReport, error) {
+	if folds < 2 {
+		folds = 2
+	}
+	if len(examples) < folds {
+		return nil, fmt.Errorf("need at least %d labelled messages, have %d", folds, len(examples))
+	}
+	tp, fp, fn, support := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+	correct := 0
+	for k := 0; k < folds; k++ {
+		var train, test []LabeledExample
+		for i, ex := range examples {
+			if i%folds == k {
+				test = append(test, ex)
+			} else {
+				train = append(train, ex)
+			}
+		}
+		model := TrainNaiveBayes(train)
+		for _, ex := range test {
+			got, _ := model.Predict(ex.Text)
+			support[ex.Label]++
+			if got == ex.Label {
+				tp[got]++
+				correct++
+			} else {
+				fp[got]++
+				fn[ex.Label]++
+			}
+		}
+	}
+	report := &EvalReport{Examples: len(examples), Folds: folds, Accuracy: float64(correct) / float64(len(examples))}
+	for label, n := range support {
+		m := LabelMetrics{Label: label, Support: n}
+		if tp[label]+fp[label] > 0 {
+			m.Precision = float64(tp[label]) / float64(tp[label]+fp[label])
+		}
+		if tp[label]+fn[label] > 0 {
+			m.Recall = float64(tp[label]) / float64(tp[label]+fn[label])
+		}
+		if m.Precision+m.Recall > 0 {
+			m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+		}
+		report.Labels = append(report.Labels, m)
+	}
+	sort.Slice(report.Labels, func(i, j int) bool { return report.Labels[i].Support > report.Labels[j].Support })
+	return report, nil
+}
+
+// WriteEvalReport prints the evaluation as a table.
+func WriteEvalReport(w io.Writer, r *EvalReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%d examples, %d-fold cross-validation, accuracy %.1f%%\n\n", r.Examples, r.Folds, r.Accuracy*100)
+	fmt.Fprintln(tw, "LABEL\tSUPPORT\tPRECISION\tRECALL\tF1")
+	for _, m := range r.Labels {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\t%.2f\n", m.Label, m.Support, m.Precision, m.Recall, m.F1)
+	}
+	return tw.Flush()
+}
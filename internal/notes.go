@@ -0,0 +1,443 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotesRecording is returned when a chat already has notes being taken.
+var ErrNotesRecording = errors.New("notes are already being taken in this chat")
+
+func initNotesTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS meeting_notes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER,
+			started_by INTEGER,
+			title TEXT,
+			started DATETIME,
+			ended DATETIME,
+			summary TEXT,
+			decisions TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS action_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER,
+			notes_id INTEGER,
+			task TEXT,
+			owner TEXT,
+			due DATETIME,
+			done BOOLEAN DEFAULT 0,
+			done_by INTEGER DEFAULT 0,
+			done_at DATETIME,
+			reminded BOOLEAN DEFAULT 0,
+			created DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_action_items_chat_done ON action_items (chat_id, done)`)
+	if err != nil {
+		return err
+	}
+	return ensureColumn("action_items", "owner_id", "INTEGER DEFAULT 0")
+}
+
+// MeetingNotes brackets a discussion in a chat between /notes start and
+// /notes stop. Ended is zero while notes are still being taken; Summary and
+// Decisions are filled in once the minutes are written.
+type MeetingNotes struct {
+	ID        int64
+	ChatID    int64
+	StartedBy int64
+	Title     string
+	Started   time.Time
+	Ended     time.Time
+	Summary   string
+	Decisions []string
+}
+
+// ActionItem is a task agreed in a discussion. Due is zero if none was set.
+type ActionItem struct {
+	ID      int64
+	ChatID  int64
+	NotesID int64
+	Task    string
+	Owner   string
+	OwnerID int64 // Telegram user ID of Owner, 0 if unknown
+	Due     time.Time
+	Done    bool
+	Created time.Time
+}
+
+// Minutes are the AI's write-up of a discussion.
+type Minutes struct {
+	Summary     string   `json:"summary"`
+	Decisions   []string `json:"decisions"`
+	ActionItems []struct {
+		Task  string `json:"task"`
+		Owner string `json:"owner"`
+		Due   string `json:"due"`
+	} `json:"action_items"`
+}
+
+const notesColumns = `id, chat_id, started_by, COALESCE(title, ''), started, ended, COALESCE(summary, ''), COALESCE(decisions, '')`
+
+func scanMeetingNotes(row *sql.Row) (*MeetingNotes, error) {
+	var n MeetingNotes
+	var ended sql.NullTime
+	var decisions string
+	err := row.Scan(&n.ID, &n.ChatID, &n.StartedBy, &n.Title, &n.Started, &ended, &n.Summary, &decisions)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n.Ended = ended.Time
+	if decisions != "" {
+		n.Decisions = strings.Split(decisions, "\n")
+	}
+	return &n, nil
+}
+
+// ActiveNotes returns the notes being taken in chatID, or nil.
+func ActiveNotes(chatID int64) (*MeetingNotes, error) {
+	return scanMeetingNotes(DB.QueryRow(`SELECT `+notesColumns+` FROM meeting_notes
+		WHERE chat_id = ? AND ended IS NULL ORDER BY id DESC LIMIT 1`, chatID))
+}
+
+// LatestNotes returns the most recently finished notes in chatID, or nil.
+func LatestNotes(chatID int64) (*MeetingNotes, error) {
+	return scanMeetingNotes(DB.QueryRow(`SELECT `+notesColumns+` FROM meeting_notes
+		WHERE chat_id = ? AND ended IS NOT NULL ORDER BY id DESC LIMIT 1`, chatID))
+}
+
+// StartNotes starts taking notes in chatID, or returns ErrNotesRecording if
+// that is already happening.
+func StartNotes(chatID, userID int64, title string) (*MeetingNotes, error) {
+	if n, err := ActiveNotes(chatID); err != nil {
+		return nil, err
+	} else if n != nil {
+		return n, ErrNotesRecording
+	}
+	n := &MeetingNotes{ChatID: chatID, StartedBy: userID, Title: title, Started: time.Now()}
+	res, err := DB.Exec(`INSERT INTO meeting_notes (chat_id, started_by, title, started) VALUES (?, ?, ?, ?)`,
+		chatID, userID, title, n.Started.UTC())
+	if err != nil {
+		return nil, err
+	}
+	n.ID, err = res.LastInsertId()
+	return n, err
+}
+
+// StopNotes stops taking notes in chatID and returns them, or nil if none
+// were being taken.
+func StopNotes(chatID int64) (*MeetingNotes, error) {
+	n, err := ActiveNotes(chatID)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	n.Ended = time.Now()
+	_, err = DB.Exec(`UPDATE meeting_notes SET ended = ? WHERE id = ?`, n.Ended.UTC(), n.ID)
+	return n, err
+}
+
+// NotesMessages returns the conversation captured by n.
+func NotesMessages(n *MeetingNotes) ([]Message, error) {
+	msgs, err := QueryMessages(MessageFilter{ChatID: n.ChatID, Since: n.Started, Until: n.Ended})
+	if err != nil {
+		return nil, err
+	}
+	return conversationMessages(msgs), nil
+}
+
+// TakeMinutes asks the model in s to write up msgs with the "minutes" prompt,
+// which must answer in the JSON form of Minutes. ctx carries the caller's
+// AITask.
+func TakeMinutes(ctx context.Context, s AISettings, chatTitle string, n *MeetingNotes, msgs []Message) (Minutes, error) {
+	var mins Minutes
+	tmpl, _ := LookupPrompt(currentConfig(), "minutes")
+	if chatTitle == "" {
+		chatTitle = "a private chat"
+	}
+	label := "the discussion"
+	if n.Title != "" {
+		label = fmt.Sprintf("the discussion %q", n.Title)
+	}
+	text, err := RenderPrompt(tmpl, PromptData{
+		Chat:    chatTitle,
+		Message: label,
+		Context: ChatTranscript(msgs, maxTranscriptChars),
+		Time:    time.Now().Format("Monday 2006-01-02 15:04"),
+	})
+	if err != nil {
+		return mins, fmt.Errorf("prompt \"minutes\": %w", err)
+	}
+	req := s.Request(text)
+	req.Format = "json"
+	resp, err := GenerateWithFallback(ctx, req)
+	if err != nil {
+		return mins, err
+	}
+	if err := json.Unmarshal([]byte(resp.Response), &mins); err != nil {
+		return mins, fmt.Errorf("minutes response is not valid JSON: %w", err)
+	}
+	return mins, nil
+}
+
+// parseDueDate reads an action item's due date as given by the model. A date
+// without a time is due at 09:00 that day.
+func parseDueDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return t
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t.Add(9 * time.Hour)
+	}
+	return time.Time{}
+}
+
+// SaveMinutes stores the minutes of n and their action items, replacing any
+// written before, and returns the notes' action items. When the minutes are
+// written again, items with the same task keep their ID and done and
+// reminded state, items already done are kept, and open items the new
+// minutes no longer mention are dropped.
+func SaveMinutes(n *MeetingNotes, mins Minutes) ([]ActionItem, error) {
+	var decisions []string
+	for _, d := range mins.Decisions {
+		if d = strings.Join(strings.Fields(d), " "); d != "" {
+			decisions = append(decisions, d)
+		}
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	n.Summary, n.Decisions = strings.TrimSpace(mins.Summary), decisions
+	if _, err := tx.Exec(`UPDATE meeting_notes SET summary = ?, decisions = ? WHERE id = ?`,
+		n.Summary, strings.Join(decisions, "\n"), n.ID); err != nil {
+		return nil, err
+	}
+	existing, err := notesActionItems(tx, n.ID)
+	if err != nil {
+		return nil, err
+	}
+	keep := map[int64]bool{}
+	now := time.Now()
+	for _, a := range mins.ActionItems {
+		item := ActionItem{
+			ChatID:  n.ChatID,
+			NotesID: n.ID,
+			Task:    strings.Join(strings.Fields(a.Task), " "),
+			Owner:   strings.TrimPrefix(strings.TrimSpace(a.Owner), "@"),
+			Due:     parseDueDate(a.Due),
+			Created: now,
+		}
+		if item.Task == "" {
+			continue
+		}
+		if item.OwnerID, err = discussionAuthor(tx, n, item.Owner); err != nil {
+			return nil, err
+		}
+		var due interface{}
+		if !item.Due.IsZero() {
+			due = item.Due.UTC()
+		}
+		if id, ok := existing[strings.ToLower(item.Task)]; ok && !keep[id] {
+			if _, err := tx.Exec(`UPDATE action_items SET owner = ?, owner_id = ?, due = ? WHERE id = ?`,
+				item.Owner, item.OwnerID, due, id); err != nil {
+				return nil, err
+			}
+			keep[id] = true
+			continue
+		}
+		res, err := tx.Exec(`INSERT INTO action_items (chat_id, notes_id, task, owner, owner_id, due, created) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			item.ChatID, item.NotesID, item.Task, item.Owner, item.OwnerID, due, now.UTC())
+		if err != nil {
+			return nil, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		keep[id] = true
+	}
+	for _, id := range existing {
+		if keep[id] {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM action_items WHERE id = ? AND NOT done`, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return queryActionItems(`notes_id = ?`, n.ID)
+}
+
+// discussionAuthor returns the user who posted under name during the
+// discussion of n, or 0 if nobody or more than one person did. The minutes
+// name owners as written in the transcript, which shows the names messages
+// were stored under.
+func discussionAuthor(tx *sql.Tx, n *MeetingNotes, name string) (int64, error) {
+	if name == "" {
+		return 0, nil
+	}
+	rows, err := tx.Query(`SELECT DISTINCT user_id FROM messages
+		WHERE chat_id = ? AND created >= ? AND created < ? AND NOT is_bot AND username = ? COLLATE NOCASE`,
+		n.ChatID, n.Started.UTC(), n.Ended.UTC(), name)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 1 {
+		return 0, rows.Err()
+	}
+	return ids[0], rows.Err()
+}
+
+// notesActionItems maps the lower-cased task of each action item of notes
+// notesID to its ID.
+func notesActionItems(tx *sql.Tx, notesID int64) (map[string]int64, error) {
+	rows, err := tx.Query(`SELECT id, COALESCE(task, '') FROM action_items WHERE notes_id = ?`, notesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := map[string]int64{}
+	for rows.Next() {
+		var id int64
+		var task string
+		if err := rows.Scan(&id, &task); err != nil {
+			return nil, err
+		}
+		items[strings.ToLower(task)] = id
+	}
+	return items, rows.Err()
+}
+
+func queryActionItems(where string, args ...interface{}) ([]ActionItem, error) {
+	rows, err := DB.Query(`SELECT id, chat_id, notes_id, COALESCE(task, ''), COALESCE(owner, ''), COALESCE(owner_id, 0), due, done, created
+		FROM action_items WHERE `+where+` ORDER BY due IS NULL, due, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ActionItem
+	for rows.Next() {
+		var a ActionItem
+		var due sql.NullTime
+		if err := rows.Scan(&a.ID, &a.ChatID, &a.NotesID, &a.Task, &a.Owner, &a.OwnerID, &due, &a.Done, &a.Created); err != nil {
+			return nil, err
+		}
+		a.Due = due.Time
+		items = append(items, a)
+	}
+	return items, rows.Err()
+}
+
+// OpenActionItems returns chatID's action items not yet done, soonest due
+// first.
+func OpenActionItems(chatID int64) ([]ActionItem, error) {
+	return queryActionItems(`chat_id = ? AND NOT done`, chatID)
+}
+
+// DueActionItems returns open action items, in every chat, that are due by t
+// and have not been reminded of yet.
+func DueActionItems(t time.Time) ([]ActionItem, error) {
+	return queryActionItems(`NOT done AND NOT reminded AND due IS NOT NULL AND due <= ?`, t.UTC())
+}
+
+// MarkActionReminded records that the reminder for item id was sent.
+func MarkActionReminded(id int64) error {
+	_, err := DB.Exec(`UPDATE action_items SET reminded = 1 WHERE id = ?`, id)
+	return err
+}
+
+// CompleteActionItem marks action item id in chatID as done by userID. It
+// reports false if there is no such open item.
+func CompleteActionItem(chatID, id, userID int64) (bool, error) {
+	res, err := DB.Exec(`UPDATE action_items SET done = 1, done_by = ?, done_at = ? WHERE id = ? AND chat_id = ? AND NOT done`,
+		userID, time.Now().UTC(), id, chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// FormatActionItem renders a as one line, e.g.
+// "#3 Write the docs — alice, due Fri Oct 23 09:00".
+func FormatActionItem(a ActionItem) string {
+	line := fmt.Sprintf("#%d %s", a.ID, a.Task)
+	var meta []string
+	if a.Owner != "" {
+		meta = append(meta, a.Owner)
+	}
+	if !a.Due.IsZero() {
+		due := "due " + a.Due.Local().Format("Mon Jan 2 15:04")
+		if !a.Done && a.Due.Before(time.Now()) {
+			due += " ⚠️ overdue"
+		}
+		meta = append(meta, due)
+	}
+	if len(meta) > 0 {
+		line += " — " + strings.Join(meta, ", ")
+	}
+	return line
+}
+
+// FormatMinutes renders the minutes of n with its action items.
+func FormatMinutes(n *MeetingNotes, messages int, items []ActionItem) string {
+	var b strings.Builder
+	b.WriteString("📝 Minutes")
+	if n.Title != "" {
+		fmt.Fprintf(&b, ": %s", n.Title)
+	}
+	fmt.Fprintf(&b, "\n%s–%s, %d messages\n", n.Started.Local().Format("Jan 2 15:04"), n.Ended.Local().Format("15:04"), messages)
+	if n.Summary != "" {
+		b.WriteString("\n" + n.Summary + "\n")
+	}
+	if len(n.Decisions) > 0 {
+		b.WriteString("\n✅ Decisions:\n")
+		for _, d := range n.Decisions {
+			b.WriteString("• " + d + "\n")
+		}
+	}
+	if len(items) > 0 {
+		b.WriteString("\n📌 Action items:\n")
+		for _, a := range items {
+			b.WriteString("• " + FormatActionItem(a) + "\n")
+		}
+		b.WriteString("\nSee /actions to track them.")
+	} else {
+		b.WriteString("\nNo action items.")
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,289 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// actionCallbackPrefix marks /actions buttons: "act:<action item id>" marks
+// the item as done.
+const actionCallbackPrefix = "act:"
+
+const notesUsage = `Usage:
+/notes start [title] — start taking notes of the discussion
+/notes stop — stop and post the minutes with decisions and action items
+/notes — show whether notes are being taken
+/notes retry — write the minutes of the last discussion again`
+
+const actionsUsage = `Usage:
+/actions — list open action items
+/actions done <id> [id...] — mark action items as done`
+
+// handleNotesCommand starts and stops note taking. Anyone can start; the
+// person who started and chat admins can stop and retry.
+func handleNotesCommand(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	sub, title := splitFirstWord(args)
+	switch strings.ToLower(sub) {
+	case "start":
+		n, err := StartNotes(m.Chat.ID, m.From.ID, strings.TrimSpace(title))
+		if errors.Is(err, ErrNotesRecording) {
+			reply(fmt.Sprintf("📝 Already taking notes since %s. Send /notes stop to finish.", n.Started.Local().Format("15:04")))
+			return
+		}
+		if err != nil {
+			reply("[Notes error] " + err.Error())
+			return
+		}
+		log.Printf("[NOTES] chat %d: started by %d", m.Chat.ID, m.From.ID)
+		reply("📝 Taking notes. Send /notes stop when you're done and I'll post the minutes.")
+	case "stop", "end":
+		n, err := ActiveNotes(m.Chat.ID)
+		if err != nil {
+			reply("[Notes error] " + err.Error())
+			return
+		}
+		if n == nil {
+			reply("No notes are being taken. Send /notes start to begin.")
+			return
+		}
+		if n.StartedBy != m.From.ID && !canManageChat(bot, m.Chat, m.From.ID) {
+			reply("⛔ Only the person who started the notes or a chat admin can stop them.")
+			return
+		}
+		if n, err = StopNotes(m.Chat.ID); err != nil || n == nil {
+			if err != nil {
+				reply("[Notes error] " + err.Error())
+			}
+			return
+		}
+		log.Printf("[NOTES] chat %d: stopped by %d", m.Chat.ID, m.From.ID)
+		go postMinutes(ctx, bot, m, n)
+	case "retry":
+		n, err := LatestNotes(m.Chat.ID)
+		if err != nil {
+			reply("[Notes error] " + err.Error())
+			return
+		}
+		if n == nil {
+			reply("No finished notes in this chat yet.")
+			return
+		}
+		if n.StartedBy != m.From.ID && !canManageChat(bot, m.Chat, m.From.ID) {
+			reply("⛔ Only the person who started the notes or a chat admin can write the minutes again.")
+			return
+		}
+		go postMinutes(ctx, bot, m, n)
+	case "":
+		n, err := ActiveNotes(m.Chat.ID)
+		if err != nil {
+			reply("[Notes error] " + err.Error())
+			return
+		}
+		if n == nil {
+			reply("No notes are being taken.\n\n" + notesUsage)
+			return
+		}
+		reply(fmt.Sprintf("📝 Taking notes since %s.\n\n%s", n.Started.Local().Format("15:04"), notesUsage))
+	default:
+		reply(notesUsage)
+	}
+}
+
+// postMinutes writes up the discussion captured by n, stores its action
+// items and posts the minutes. It runs in its own goroutine and may wait in
+// the AI queue.
+func postMinutes(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, n *MeetingNotes) {
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	msgs, err := NotesMessages(n)
+	if err != nil {
+		reply("[Notes error] " + err.Error())
+		return
+	}
+	if len(msgs) == 0 {
+		reply("📝 Notes stopped. Nothing was said, so there are no minutes.")
+		return
+	}
+	bot.Send(tgbotapi.NewChatAction(m.Chat.ID, tgbotapi.ChatTyping))
+	task := aiTaskFor(m, "minutes")
+	task.OnWait = queueNotifier(bot, m)
+	mins, err := TakeMinutes(WithAITask(ctx, task), ResolveAISettings(m.Chat.ID, 0), m.Chat.Title, n, msgs)
+	if text, ok := aiLimitText(err); ok {
+		if text != "" {
+			reply(text + "\nSend /notes retry to write the minutes later.")
+		}
+		return
+	}
+	if errors.Is(err, ErrAIUnavailable) {
+		reply(DegradedMessage + "\nSend /notes retry to write the minutes later.")
+		return
+	}
+	if err != nil {
+		reply("[AI error] " + err.Error() + "\nSend /notes retry to try again.")
+		return
+	}
+	items, err := SaveMinutes(n, mins)
+	if err != nil {
+		reply("[Notes error] " + err.Error())
+		return
+	}
+	sendLongMessage(bot, m.Chat.ID, FormatMinutes(n, len(msgs), items))
+}
+
+// handleActionsCommand lists the chat's open action items with buttons to
+// mark them done, or marks the given items done.
+func handleActionsCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	sub, rest := splitFirstWord(args)
+	switch strings.ToLower(sub) {
+	case "":
+		text, markup, err := actionsMessage(m.Chat.ID)
+		if err != nil {
+			reply("[Actions error] " + err.Error())
+			return
+		}
+		msg := tgbotapi.NewMessage(m.Chat.ID, text)
+		if markup != nil {
+			msg.ReplyMarkup = *markup
+		}
+		bot.Send(msg)
+	case "done":
+		var done, missing []string
+		for _, f := range strings.Fields(rest) {
+			id, err := strconv.ParseInt(strings.TrimPrefix(f, "#"), 10, 64)
+			if err != nil {
+				reply(actionsUsage)
+				return
+			}
+			ok, err := CompleteActionItem(m.Chat.ID, id, m.From.ID)
+			if err != nil {
+				reply("[Actions error] " + err.Error())
+				return
+			}
+			if ok {
+				done = append(done, "#"+strconv.FormatInt(id, 10))
+			} else {
+				missing = append(missing, "#"+strconv.FormatInt(id, 10))
+			}
+		}
+		if len(done)+len(missing) == 0 {
+			reply(actionsUsage)
+			return
+		}
+		var text []string
+		if len(done) > 0 {
+			text = append(text, "✅ Done: "+strings.Join(done, ", "))
+		}
+		if len(missing) > 0 {
+			text = append(text, "No open action items "+strings.Join(missing, ", ")+" here.")
+		}
+		reply(strings.Join(text, "\n"))
+	default:
+		reply(actionsUsage)
+	}
+}
+
+// actionsMessage renders chatID's open action items and a keyboard with a
+// button per item; markup is nil when there are none.
+func actionsMessage(chatID int64) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	items, err := OpenActionItems(chatID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(items) == 0 {
+		return "📌 No open action items. Use /notes start and /notes stop to capture some.", nil, nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "📌 Open action items (%d), tap to mark done:\n", len(items))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, a := range items {
+		b.WriteString("• " + FormatActionItem(a) + "\n")
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ #%d", a.ID), fmt.Sprintf("%s%d", actionCallbackPrefix, a.ID)))
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return b.String(), &markup, nil
+}
+
+// handleActionCallback marks an action item done from an /actions or
+// reminder button and refreshes the list it was tapped on.
+func handleActionCallback(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(cq.Data, actionCallbackPrefix), 10, 64)
+	if err != nil || cq.Message == nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Invalid action button"))
+		return
+	}
+	chatID := cq.Message.Chat.ID
+	ok, err := CompleteActionItem(chatID, id, cq.From.ID)
+	switch {
+	case err != nil:
+		bot.Request(tgbotapi.NewCallback(cq.ID, "[Actions error] "+err.Error()))
+		return
+	case !ok:
+		bot.Request(tgbotapi.NewCallback(cq.ID, fmt.Sprintf("#%d is already done", id)))
+	default:
+		log.Printf("[ACTIONS] chat %d: #%d done by %d", chatID, id, cq.From.ID)
+		bot.Request(tgbotapi.NewCallback(cq.ID, fmt.Sprintf("✅ #%d done", id)))
+	}
+	if strings.HasPrefix(cq.Message.Text, "⏰") {
+		// A reminder: close it rather than turning it into the full list.
+		bot.Request(tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, cq.Message.Text+"\n✅ Done"))
+		return
+	}
+	text, markup, err := actionsMessage(chatID)
+	if err != nil {
+		return
+	}
+	if markup == nil {
+		bot.Request(tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, text))
+		return
+	}
+	bot.Request(tgbotapi.NewEditMessageTextAndMarkup(chatID, cq.Message.MessageID, text, *markup))
+}
+
+// StartActionReminder reminds chats of action items when they fall due,
+// checking every minute until ctx is cancelled. Each item is reminded of
+// once; silent chats are skipped.
+func StartActionReminder(ctx context.Context, bot *tgbotapi.BotAPI) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		items, err := DueActionItems(time.Now())
+		if err != nil {
+			log.Printf("Action reminder: %v", err)
+			continue
+		}
+		for _, a := range items {
+			if err := MarkActionReminded(a.ID); err != nil {
+				log.Printf("Action reminder: %v", err)
+				continue
+			}
+			if GetChatSettings(a.ChatID).Mode == ModeSilent {
+				continue
+			}
+			msg := tgbotapi.NewMessage(a.ChatID, "⏰ Action item due: "+FormatActionItem(a))
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ Mark done", fmt.Sprintf("%s%d", actionCallbackPrefix, a.ID))))
+			bot.Send(msg)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// takeNotes records a discussion in chatID started by startedBy in which each
+// of posts, user ID to name, said something, and returns the finished notes.
+func takeNotes(t *testing.T, chatID, startedBy int64, posts map[int64]string) *MeetingNotes {
+	t.Helper()
+	n, err := StartNotes(chatID, startedBy, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Started = n.Started.Add(-time.Minute)
+	DB.Exec(`UPDATE meeting_notes SET started = ? WHERE id = ?`, n.Started.UTC(), n.ID)
+	for id, name := range posts {
+		if _, err := SaveMessage(Message{ChatID: chatID, UserID: id, Username: name, Text: "hi", Created: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err = StopNotes(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// minutes builds Minutes with one action item per task, owned by owners[i]
+// when given.
+func minutes(tasks []string, owners ...string) Minutes {
+	var m Minutes
+	for i, task := range tasks {
+		m.ActionItems = append(m.ActionItems, struct {
+			Task  string `json:"task"`
+			Owner string `json:"owner"`
+			Due   string `json:"due"`
+		}{Task: task})
+		if i < len(owners) {
+			m.ActionItems[i].Owner = owners[i]
+		}
+	}
+	return m
+}
+
+func TestForgetUserNotes(t *testing.T) {
+	useTestDB(t)
+	// Two people called Alex: erasing one must not touch the other's items.
+	n := takeNotes(t, 1, 8, map[int64]string{7: "Alex", 8: "bob"})
+	if _, err := SaveMinutes(n, minutes([]string{"docs", "tests"}, "alex", "bob")); err != nil {
+		t.Fatal(err)
+	}
+	other := takeNotes(t, 2, 9, map[int64]string{9: "Alex"})
+	if _, err := SaveMinutes(other, minutes([]string{"release"}, "Alex")); err != nil {
+		t.Fatal(err)
+	}
+	started := takeNotes(t, 1, 7, map[int64]string{8: "bob"})
+	if _, err := SaveMinutes(started, minutes([]string{"review"}, "bob")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ForgetUser(7, 0, "test"); err != nil {
+		t.Fatal(err)
+	}
+	var tasks []string
+	rows, err := DB.Query(`SELECT task FROM action_items ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var task string
+		rows.Scan(&task)
+		tasks = append(tasks, task)
+	}
+	if want := "tests,release"; strings.Join(tasks, ",") != want {
+		t.Errorf("action items left = %s, want %s", strings.Join(tasks, ","), want)
+	}
+	var notes int
+	DB.QueryRow(`SELECT COUNT(*) FROM meeting_notes WHERE started_by = 7`).Scan(&notes)
+	if notes != 0 {
+		t.Errorf("%d meeting notes started by the user are left", notes)
+	}
+}
+
+func TestSaveMinutesRetryKeepsItems(t *testing.T) {
+	useTestDB(t)
+	n := takeNotes(t, 1, 7, map[int64]string{7: "ann"})
+	first, err := SaveMinutes(n, minutes([]string{"Write docs", "Fix the build", "Old idea"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int64{}
+	for _, a := range first {
+		ids[a.Task] = a.ID
+	}
+	if ok, err := CompleteActionItem(1, ids["Fix the build"], 7); !ok || err != nil {
+		t.Fatalf("CompleteActionItem = %v, %v", ok, err)
+	}
+	if err := MarkActionReminded(ids["Write docs"]); err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry words one task differently and no longer mentions the others.
+	second, err := SaveMinutes(n, minutes([]string{"write  docs", "Release 1.2"}, "ann"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]ActionItem{}
+	for _, a := range second {
+		got[a.Task] = a
+	}
+	if len(got) != 3 {
+		t.Errorf("items after retry = %+v, want Write docs, Fix the build and Release 1.2", second)
+	}
+	if a, ok := got["Fix the build"]; !ok || !a.Done || a.ID != ids["Fix the build"] {
+		t.Errorf("done item after retry = %+v, %v, want it kept as done", a, ok)
+	}
+	docs, ok := got["Write docs"]
+	if !ok || docs.ID != ids["Write docs"] || docs.Owner != "ann" || docs.OwnerID != 7 {
+		t.Errorf("matching item after retry = %+v, %v, want ID %d owned by ann (7)", docs, ok, ids["Write docs"])
+	}
+	var reminded bool
+	if err := DB.QueryRow(`SELECT reminded FROM action_items WHERE id = ?`, ids["Write docs"]).Scan(&reminded); err != nil || !reminded {
+		t.Errorf("matching item reminded = %v, %v, want true", reminded, err)
+	}
+	if _, ok := got["Old idea"]; ok {
+		t.Errorf("open item the retry dropped is still there")
+	}
+	if _, ok := got["Release 1.2"]; !ok {
+		t.Errorf("new item from the retry is missing")
+	}
+}
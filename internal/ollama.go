@@ -1,3 +1,240 @@
 package internal
 
-// TODO: Implement Ollama AI API client logic
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ollamaBaseURL is the local Ollama server; tests point it at a fake one.
+var ollamaBaseURL = "http://localhost:11434"
+
+const (
+	embedTimeout  = time.Minute // per embeddings request, once it has a queue slot
+	embedPriority = -1          // background embeddings wait behind every chat request
+)
+
+// OllamaEmbed returns the embedding vector for text using Ollama's embeddings
+// endpoint. Like generation it takes a slot in the AI queue: as the AITask in
+// ctx, or at background priority when ctx carries none.
+func OllamaEmbed(ctx context.Context, text, model string) ([]float32, error) {
+	if _, ok := ctx.Value(aiTaskKey{}).(AITask); !ok {
+		ctx = WithAITask(ctx, AITask{Kind: "embed", Priority: embedPriority})
+	}
+	ctx, release, err := defaultAIQueue.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(ctx, embedTimeout)
+	defer cancel()
+	payload, _ := json.Marshal(map[string]string{"model": model, "prompt": text})
+	req, err := http.NewRequestWithContext(ctx, "POST", ollamaBaseURL+"/api/embeddings", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var o struct {
+		Embedding []float32 `json:"embedding"`
+		Error     string    `json:"error"`
+	}
+	if err := json.Unmarshal(body, &o); err != nil {
+		return nil, fmt.Errorf("ollama embeddings response: %s", string(body))
+	}
+	if o.Error != "" {
+		return nil, fmt.Errorf("ollama embeddings: %s", o.Error)
+	}
+	if len(o.Embedding) == 0 {
+		return nil, fmt.Errorf("ollama embeddings: empty vector for model %s", model)
+	}
+	return o.Embedding, nil
+}
+
+// OllamaModelDetails describes a model's format and size class.
+type OllamaModelDetails struct {
+	ParentModel       string `json:"parent_model"`
+	Format            string `json:"format"`
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+// OllamaModel is an installed model as reported by /api/tags.
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	ModifiedAt time.Time          `json:"modified_at"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+// ListOllamaModels returns the models installed in the local Ollama.
+func ListOllamaModels() ([]OllamaModel, error) {
+	resp, err := http.Get(ollamaBaseURL + "/api/tags")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Models []OllamaModel `json:"models"`
+		Error  string        `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("ollama tags response: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", result.Error)
+	}
+	return result.Models, nil
+}
+
+// FindOllamaModel returns the installed model called name. A name without a
+// tag matches its ":latest" tag.
+func FindOllamaModel(name string) (*OllamaModel, error) {
+	models, err := ListOllamaModels()
+	if err != nil {
+		return nil, err
+	}
+	for i := range models {
+		if models[i].Name == name || models[i].Name == name+":latest" {
+			return &models[i], nil
+		}
+	}
+	return nil, fmt.Errorf("model %q is not installed", name)
+}
+
+// OllamaModelInfo is the /api/show response for a model.
+type OllamaModelInfo struct {
+	License    string                 `json:"license"`
+	Modelfile  string                 `json:"modelfile"`
+	Parameters string                 `json:"parameters"`
+	Template   string                 `json:"template"`
+	Details    OllamaModelDetails     `json:"details"`
+	ModelInfo  map[string]interface{} `json:"model_info"`
+	Error      string                 `json:"error"`
+}
+
+// ContextLength returns the model's context window, or 0 if unknown.
+func (i *OllamaModelInfo) ContextLength() int {
+	for k, v := range i.ModelInfo {
+		if strings.HasSuffix(k, ".context_length") {
+			if f, ok := v.(float64); ok {
+				return int(f)
+			}
+		}
+	}
+	return 0
+}
+
+// OllamaShow returns details about an installed model.
+func OllamaShow(name string) (*OllamaModelInfo, error) {
+	payload, _ := json.Marshal(map[string]string{"name": name})
+	resp, err := http.Post(ollamaBaseURL+"/api/show", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var info OllamaModelInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("ollama show response: %w", err)
+	}
+	if info.Error != "" {
+		return nil, fmt.Errorf("ollama: %s", info.Error)
+	}
+	return &info, nil
+}
+
+// PullProgress is one status line streamed by /api/pull.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error"`
+}
+
+// Percent returns the download progress of the current layer, or -1.
+func (p PullProgress) Percent() int {
+	if p.Total <= 0 {
+		return -1
+	}
+	return int(p.Completed * 100 / p.Total)
+}
+
+// OllamaPull downloads a model, calling progress for every status update.
+func OllamaPull(ctx context.Context, name string, progress func(PullProgress)) error {
+	payload, _ := json.Marshal(map[string]interface{}{"name": name, "stream": true})
+	req, err := http.NewRequestWithContext(ctx, "POST", ollamaBaseURL+"/api/pull", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var p PullProgress
+		if err := dec.Decode(&p); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("ollama pull response: %w", err)
+		}
+		if p.Error != "" {
+			return fmt.Errorf("ollama: %s", p.Error)
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+}
+
+// OllamaDelete removes an installed model.
+func OllamaDelete(name string) error {
+	payload, _ := json.Marshal(map[string]string{"name": name})
+	req, err := http.NewRequest("DELETE", ollamaBaseURL+"/api/delete", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("model %q not found", name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama delete: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// FormatBytes renders a size such as 4.7 GB.
+func FormatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultPrompts are the built-in prompt templates, written to configs.json
+// on first run and used for any name missing from the config.
+func defaultPrompts() map[string]string {
+	return map[string]string{
+		"default": `You are a software engineering assistant bot in the Telegram chat "{{.Chat}}". Message type: {{.Type}}. Be concise and helpful.
+{{if .Context}}
+Recent conversation:
+{{.Context}}
+{{end}}
+User message from {{.User}}: {{.Message}}`,
+		"issue": `You are a software engineering assistant bot helping triage a bug report in "{{.Chat}}".
+Acknowledge the problem, suggest the most likely causes, and ask for whatever is missing: steps to reproduce, expected vs actual behaviour, versions and error output. Be brief.
+{{if .Context}}
+Recent conversation:
+{{.Context}}
+{{end}}
+Report from {{.User}}: {{.Message}}`,
+		"question": `You are a software engineering assistant bot answering a question in "{{.Chat}}".
+Answer directly and accurately. If you are not sure, say so and suggest where to look.
+{{if .Context}}
+Recent conversation:
+{{.Context}}
+{{end}}
+Question from {{.User}}: {{.Message}}`,
+		"feature_request": `You are a software engineering assistant bot in "{{.Chat}}". {{.User}} is proposing a feature.
+Restate the request in one sentence, then ask about the use case and what "done" looks like. Do not promise delivery.
+{{if .Context}}
+Recent conversation:
+{{.Context}}
+{{end}}
+Proposal: {{.Message}}`,
+		"vision": `You are a software engineering assistant bot in the Telegram chat "{{.Chat}}". {{.User}} sent the attached image.
+If it shows an error, stack trace or failing output, quote the key error, explain the likely cause and suggest a fix. Otherwise briefly describe what it shows.
+{{if .Message}}
+Their message: {{.Message}}
+{{end}}`,
+		"document": `You are a software engineering assistant bot in the Telegram chat "{{.Chat}}". {{.User}} shared the file {{.DocumentName}}.
+Summarize it for the team in a few short bullet points. For logs or stack traces, lead with the errors and their likely cause; for design docs, the key decisions and open questions.
+{{if .Message}}
+Their message: {{.Message}}
+{{end}}
+--- {{.DocumentName}} ---
+{{.Document}}`,
+		"document_question": `You are a software engineering assistant bot in the Telegram chat "{{.Chat}}". Answer the question concisely, using the file {{.DocumentName}} below where it is relevant.
+
+--- {{.DocumentName}} ---
+{{.Document}}
+---
+
+Question from {{.User}}: {{.Message}}`,
+		"tldr": `Summarize this conversation from the Telegram chat "{{.Chat}}" ({{.Message}}) for someone who missed it. Use at most 6 short bullet points covering the main topics, decisions and anything left unresolved. Mention people by name only when it matters.
+
+{{.Context}}`,
+		"digest": `Write a digest of the Telegram chat "{{.Chat}}" for {{.Message}}. Use two short sections, each with at most 5 bullet points:
+Key discussions: the main topics and any decisions made.
+Open questions: questions that were asked but not answered.
+Leave out a section if there is nothing for it. Do not add an introduction.
+
+{{.Context}}`,
+		"minutes": `Write the minutes of {{.Message}} in the Telegram chat "{{.Chat}}". It is now {{.Time}}.
+Respond with JSON only: {"summary": "<2-4 sentences>", "decisions": ["<decision>"], "action_items": [{"task": "<what needs doing>", "owner": "<name as written in the transcript, or empty>", "due": "<YYYY-MM-DD or YYYY-MM-DD HH:MM, or empty>"}]}
+Only list decisions and action items that were actually agreed. Resolve relative dates such as "by Friday" against the current time.
+
+{{.Context}}`,
+		"concise": `Reply in one concise sentence. Use two only if absolutely necessary, and use as few words as possible.
+
+User message: {{.Message}}`,
+	}
+}
+
+// defaultTypePrompts maps message labels to the prompt used for them.
+func defaultTypePrompts() map[string]string {
+	return map[string]string{
+		LabelIssue:          "issue",
+		LabelQuestion:       "question",
+		LabelFeatureRequest: "feature_request",
+	}
+}
+
+// PromptData are the variables available to prompt templates.
+type PromptData struct {
+	Type    string // classified message type
+	Chat    string // chat title
+	ChatID  int64
+	User    string // sender's name
+	Message string
+	Context string // recent messages in the chat, one "name: text" per line
+	Time    string
+
+	DocumentName string // file name of the document being discussed, if any
+	Document     string // its text, shortened to fit the prompt
+}
+
+func initPromptColumns() error {
+	return ensureColumn("chat_settings", "prompt", "TEXT")
+}
+
+// PromptNames lists every configured and built-in prompt name, sorted.
+func PromptNames(cfg *Configs) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range []map[string]string{cfg.Prompts, defaultPrompts()} {
+		for name := range m {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupPrompt returns the template text for name from the config, falling
+// back to the built-in prompts.
+func LookupPrompt(cfg *Configs, name string) (string, bool) {
+	if text, ok := cfg.Prompts[name]; ok {
+		return text, true
+	}
+	text, ok := defaultPrompts()[name]
+	return text, ok
+}
+
+// PromptForType returns the prompt name mapped to a message type, or "default".
+func PromptForType(cfg *Configs, msgType string) string {
+	mapping := cfg.TypePrompts
+	if mapping == nil {
+		mapping = defaultTypePrompts()
+	}
+	if name, ok := mapping[msgType]; ok {
+		if _, ok := LookupPrompt(cfg, name); ok {
+			return name
+		}
+	}
+	return "default"
+}
+
+// RenderPrompt executes a prompt template with data.
+func RenderPrompt(text string, data PromptData) (string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// GetChatPrompt returns the prompt a chat selected with /prompt use, if any.
+func GetChatPrompt(chatID int64) string {
+	var name sql.NullString
+	if DB == nil || DB.QueryRow(`SELECT prompt FROM chat_settings WHERE chat_id = ?`, chatID).Scan(&name) != nil {
+		return ""
+	}
+	return name.String
+}
+
+// SetChatPrompt makes every AI reply in chatID use the named prompt. An empty
+// name returns to the per-message-type prompts.
+func SetChatPrompt(chatID int64, name string) error {
+	var v interface{}
+	if name != "" {
+		if _, ok := LookupPrompt(currentConfig(), name); !ok {
+			return fmt.Errorf("no prompt named %q", name)
+		}
+		v = name
+	}
+	_, err := DB.Exec(`INSERT INTO chat_settings (chat_id, prompt, updated) VALUES (?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET prompt = excluded.prompt, updated = excluded.updated`,
+		chatID, v, time.Now().UTC())
+	return err
+}
+
+// SelectPrompt picks the prompt for a message: the chat's chosen prompt, then
+// the prompt mapped to the message type, then "default".
+func SelectPrompt(cfg *Configs, chatID int64, msgType string) string {
+	if name := GetChatPrompt(chatID); name != "" {
+		if _, ok := LookupPrompt(cfg, name); ok {
+			return name
+		}
+	}
+	return PromptForType(cfg, msgType)
+}
+
+// RecentContext formats up to n messages from chatID before excludeID (a
+// Telegram message ID, 0 for none) as "name: text" lines.
+func RecentContext(chatID int64, excludeID int64, n int) string {
+	msgs, err := QueryMessages(MessageFilter{ChatID: chatID, Limit: n + 1})
+	if err != nil {
+		return ""
+	}
+	var lines []string
+	for _, m := range msgs {
+		if (excludeID != 0 && m.MessageID == excludeID) || strings.TrimSpace(m.Text) == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", m.Username, strings.ReplaceAll(m.Text, "\n", " ")))
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cachedQuestionAsker stands in for the sender's name in the prompt of a
+// cached question, whose answer may be replayed to someone else.
+const cachedQuestionAsker = "a chat member"
+
+// buildAIPrompt renders the prompt selected for the message's chat and type
+// and returns the response cache key for the reply, or nil if the reply
+// should not be reused. Only questions are cached, per chat, keyed on their
+// text and the text of the prompt template answering them, so editing a
+// template retires its old answers. A cached question is asked without the
+// recent conversation or the sender's name, so its answer suits whoever asks
+// it next. Replies to another message depend on that message and templates
+// using .Time on the time, so neither is cached.
+func buildAIPrompt(m *tgbotapi.Message, msgType string) (string, *CacheKey) {
+	cfg := currentConfig()
+	name := SelectPrompt(cfg, m.Chat.ID, msgType)
+	tmpl, _ := LookupPrompt(cfg, name)
+	if msgType != LabelQuestion || m.ReplyToMessage != nil || strings.Contains(tmpl, ".Time") {
+		return renderMessagePrompt(name, m, msgType, m.Text, nil), nil
+	}
+	data := messagePromptData(m, msgType, m.Text)
+	data.User = cachedQuestionAsker
+	return renderPromptData(name, tmpl, data), &CacheKey{Question: m.Text, Context: []string{name, tmpl}, PerChat: true}
+}
+
+// renderMessagePrompt renders the named prompt for m, with text as the
+// message and doc, if not nil, as the document.
+func renderMessagePrompt(name string, m *tgbotapi.Message, msgType, text string, doc *ChatDocument) string {
+	tmpl, _ := LookupPrompt(currentConfig(), name)
+	data := messagePromptData(m, msgType, text)
+	if doc != nil {
+		data.DocumentName = doc.FileName
+		data.Document = documentExcerpt(doc.Content, maxDocumentPrompt)
+	}
+	if strings.Contains(tmpl, ".Context") {
+		data.Context = RecentContext(m.Chat.ID, int64(m.MessageID), 6)
+	}
+	return renderPromptData(name, tmpl, data)
+}
+
+// messagePromptData fills in the prompt variables that come from m itself.
+func messagePromptData(m *tgbotapi.Message, msgType, text string) PromptData {
+	data := PromptData{
+		Type:    msgType,
+		Chat:    m.Chat.Title,
+		ChatID:  m.Chat.ID,
+		Message: text,
+		Time:    time.Now().Format("2006-01-02 15:04"),
+	}
+	if data.Chat == "" {
+		data.Chat = "a private chat"
+	}
+	if m.From != nil {
+		data.User = m.From.FirstName
+		if data.User == "" {
+			data.User = m.From.UserName
+		}
+	}
+	return data
+}
+
+// renderPromptData renders tmpl, the prompt called name, falling back to a
+// generic prompt if the template is broken.
+func renderPromptData(name, tmpl string, data PromptData) string {
+	prompt, err := RenderPrompt(tmpl, data)
+	if err != nil {
+		log.Printf("Error rendering prompt %q: %v", name, err)
+		return fmt.Sprintf("Message type: %s. Be concise and helpful.\n\nUser message: %s", data.Type, data.Message)
+	}
+	return prompt
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const promptUsage = `Usage:
+/prompt — show which prompts this chat uses
+/prompt list
+/prompt show <name>
+/prompt use <name> — use one prompt for every AI reply here (chat admins)
+/prompt use off — back to per-message-type prompts`
+
+// handlePromptCommand lists the prompt library and selects a chat's prompt.
+func handlePromptCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	cfg := currentConfig()
+	sub, rest := splitFirstWord(args)
+	reply := func(text string) { sendLongMessage(bot, m.Chat.ID, text) }
+	switch strings.ToLower(sub) {
+	case "":
+		reply(describeChatPrompts(cfg, m.Chat.ID) + "\n\n" + promptUsage)
+	case "list":
+		reply("Prompts: " + strings.Join(PromptNames(cfg), ", "))
+	case "show":
+		text, ok := LookupPrompt(cfg, rest)
+		if !ok {
+			reply(fmt.Sprintf("No prompt named %q.", rest))
+			return
+		}
+		reply(fmt.Sprintf("📝 %s\n\n%s", rest, text))
+	case "use":
+		if rest == "" {
+			reply(promptUsage)
+			return
+		}
+		if !canManageChat(bot, m.Chat, m.From.ID) {
+			reply("⛔ Only chat admins can change this chat's prompt.")
+			return
+		}
+		name := rest
+		if strings.EqualFold(name, "off") || strings.EqualFold(name, "auto") {
+			name = ""
+		}
+		if err := SetChatPrompt(m.Chat.ID, name); err != nil {
+			reply("[Prompt error] " + err.Error())
+			return
+		}
+		log.Printf("[PROMPT] chat %d: use %q by %d", m.Chat.ID, name, m.From.ID)
+		reply("✅ " + describeChatPrompts(cfg, m.Chat.ID))
+	default:
+		reply(promptUsage)
+	}
+}
+
+// describeChatPrompts explains which prompt each message type gets in chatID.
+func describeChatPrompts(cfg *Configs, chatID int64) string {
+	if name := GetChatPrompt(chatID); name != "" {
+		return fmt.Sprintf("This chat uses the %q prompt for every AI reply.", name)
+	}
+	var lines []string
+	for _, label := range Labels {
+		lines = append(lines, fmt.Sprintf("%s → %s", label, PromptForType(cfg, label)))
+	}
+	return "This chat uses per-message-type prompts:\n" + strings.Join(lines, "\n")
+}
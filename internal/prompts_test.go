@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// useFakeOllama answers every generate request with response and returns the
+// number of requests made so far.
+func useFakeOllama(t *testing.T, response string) func() int32 {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(OllamaResponse{Model: req.Model, Response: response, Done: true})
+	}))
+	old := ollamaBaseURL
+	ollamaBaseURL = srv.URL
+	t.Cleanup(func() {
+		ollamaBaseURL = old
+		srv.Close()
+	})
+	return func() int32 { return atomic.LoadInt32(&calls) }
+}
+
+func TestQuestionReplyCachedOnSecondAsk(t *testing.T) {
+	useTestDB(t)
+	useQueueConfig(t, AIQueueConfig{}) // default config
+	calls := useFakeOllama(t, "Run go test ./...")
+	chat := &tgbotapi.Chat{ID: -100, Type: "group", Title: "Dev"}
+	ask := func(id int, from, text string) (string, string) {
+		m := &tgbotapi.Message{MessageID: id, Chat: chat, From: &tgbotapi.User{ID: int64(id), FirstName: from}, Text: text}
+		SaveMessage(Message{ChatID: chat.ID, MessageID: int64(id), UserID: m.From.ID, Username: from, Text: text, Created: time.Now()})
+		prompt, key := buildAIPrompt(m, LabelQuestion)
+		if key == nil {
+			t.Fatalf("%q: no cache key for a question with the default prompts", text)
+		}
+		reply, err := settingsAIChat(WithAITask(context.Background(), aiTaskFor(m, "ai")), ResolveAISettings(chat.ID, m.From.ID), prompt, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prompt, reply
+	}
+
+	prompt, first := ask(1, "Ann", "How do I run the tests?")
+	if strings.Contains(prompt, "Ann") {
+		t.Errorf("cached prompt names the sender:\n%s", prompt)
+	}
+	SaveMessage(Message{ChatID: chat.ID, MessageID: 2, UserID: 3, Username: "Cy", Text: "the build is red", Created: time.Now()})
+	_, second := ask(4, "Bob", "how do i run the tests")
+	if second != first {
+		t.Errorf("second answer = %q, want the cached %q", second, first)
+	}
+	if n := calls(); n != 1 {
+		t.Errorf("Ollama was called %d times, want 1", n)
+	}
+}
@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+func initRetentionTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created DATETIME DEFAULT CURRENT_TIMESTAMP,
+			actor TEXT,
+			action TEXT,
+			chat_id INTEGER,
+			user_id INTEGER,
+			count INTEGER,
+			detail TEXT
+		)
+	`)
+	return err
+}
+
+// AuditEntry is one recorded deletion.
+type AuditEntry struct {
+	ID      int64
+	Created time.Time
+	Actor   string
+	Action  string
+	ChatID  int64
+	UserID  int64
+	Count   int64
+	Detail  string
+}
+
+// WriteAudit records a deletion in the audit log.
+func WriteAudit(actor, action string, chatID, userID, count int64, detail string) error {
+	_, err := DB.Exec(`INSERT INTO audit_log (created, actor, action, chat_id, user_id, count, detail) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC(), actor, action, chatID, userID, count, detail)
+	return err
+}
+
+// RecentAuditEntries returns the newest audit log entries first.
+func RecentAuditEntries(limit int) ([]AuditEntry, error) {
+	rows, err := DB.Query(`SELECT id, created, actor, action, chat_id, user_id, count, COALESCE(detail, '') FROM audit_log ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Created, &e.Actor, &e.Action, &e.ChatID, &e.UserID, &e.Count, &e.Detail); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// deleteMessagesWhere removes messages matching the SQL condition together
+// with the data derived from them, and returns how many messages were removed.
+func deleteMessagesWhere(tx *sql.Tx, where string, args ...interface{}) (int64, error) {
+	if _, err := tx.Exec(`DELETE FROM message_embeddings WHERE message_id IN (SELECT id FROM messages WHERE `+where+`)`, args...); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_documents WHERE (chat_id, message_id) IN (SELECT chat_id, message_id FROM messages WHERE `+where+`)`, args...); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`DELETE FROM messages WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PurgeMessages deletes the messages selected by f (Limit is ignored) and
+// records the deletion under actor. An empty filter deletes everything.
+func PurgeMessages(f MessageFilter, actor string) (int64, error) {
+	where := []string{"1=1"}
+	args := []interface{}{}
+	var detail []string
+	if f.ChatID != 0 {
+		where = append(where, "chat_id = ?")
+		args = append(args, f.ChatID)
+	}
+	if f.UserID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, f.Since.UTC())
+		detail = append(detail, "since "+f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created < ?")
+		args = append(args, f.Until.UTC())
+		detail = append(detail, "before "+f.Until.Format(time.RFC3339))
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	n, err := deleteMessagesWhere(tx, strings.Join(where, " AND "), args...)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, WriteAudit(actor, "purge", f.ChatID, f.UserID, n, strings.Join(detail, ", "))
+}
+
+// ForgetUser erases everything stored about a user: their messages, the
+// embeddings derived from them, their AI usage records, the cached AI
+// answers to their questions, the meeting notes they started with those
+// notes' action items, and the action items assigned to them. chatID limits
+// the erasure to one chat; 0 erases across all chats.
+func ForgetUser(userID, chatID int64, actor string) (int64, error) {
+	where := "user_id = ?"
+	args := []interface{}{userID}
+	if chatID != 0 {
+		where += " AND chat_id = ?"
+		args = append(args, chatID)
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	n, err := deleteMessagesWhere(tx, where, args...)
+	if err != nil {
+		return 0, err
+	}
+	if err := forgetUserNotes(tx, userID, chatID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM ai_requests WHERE `+where, args...); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM ai_cache WHERE `+where, args...); err != nil {
+		return 0, err
+	}
+	if chatID == 0 {
+		// Personal settings are not per chat, so only a full erasure drops them.
+		if _, err := tx.Exec(`DELETE FROM user_settings WHERE user_id = ?`, userID); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, WriteAudit(actor, "forget_user", chatID, userID, n, "")
+}
+
+// forgetUserNotes deletes the meeting notes userID started, with their action
+// items, and the action items assigned to userID. Items the user ticked off
+// stay, but no longer record who did it.
+func forgetUserNotes(tx *sql.Tx, userID, chatID int64) error {
+	notesWhere, itemsWhere := "started_by = ?", "1"
+	notesArgs, itemsArgs := []interface{}{userID}, []interface{}{}
+	if chatID != 0 {
+		notesWhere += " AND chat_id = ?"
+		notesArgs = append(notesArgs, chatID)
+		itemsWhere = "chat_id = ?"
+		itemsArgs = append(itemsArgs, chatID)
+	}
+	if _, err := tx.Exec(`DELETE FROM action_items WHERE notes_id IN (SELECT id FROM meeting_notes WHERE `+notesWhere+`)`, notesArgs...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM meeting_notes WHERE `+notesWhere, notesArgs...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM action_items WHERE owner_id = ? AND `+itemsWhere, append([]interface{}{userID}, itemsArgs...)...); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE action_items SET done_by = 0 WHERE done_by = ? AND `+itemsWhere, append([]interface{}{userID}, itemsArgs...)...)
+	return err
+}
+
+// PruneMessages applies the configured retention policies to every chat and
+// returns the total number of messages deleted.
+func PruneMessages() (int64, error) {
+	retention := currentConfig().Retention
+	rows, err := DB.Query(`SELECT DISTINCT chat_id FROM messages`)
+	if err != nil {
+		return 0, err
+	}
+	var chats []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		chats = append(chats, id)
+	}
+	rows.Close()
+
+	var total int64
+	for _, chatID := range chats {
+		policy := retention.PolicyFor(chatID)
+		if policy.MaxAgeDays > 0 {
+			cutoff := time.Now().AddDate(0, 0, -policy.MaxAgeDays)
+			n, err := pruneChat(`chat_id = ? AND created < ?`, chatID, cutoff.UTC())
+			if err != nil {
+				return total, err
+			}
+			if n > 0 {
+				WriteAudit("retention", "prune_age", chatID, 0, n, fmt.Sprintf("older than %d days", policy.MaxAgeDays))
+			}
+			total += n
+		}
+		if policy.MaxMessages > 0 {
+			n, err := pruneChat(`chat_id = ? AND id NOT IN (SELECT id FROM messages WHERE chat_id = ? ORDER BY created DESC, id DESC LIMIT ?)`,
+				chatID, chatID, policy.MaxMessages)
+			if err != nil {
+				return total, err
+			}
+			if n > 0 {
+				WriteAudit("retention", "prune_count", chatID, 0, n, fmt.Sprintf("keep newest %d", policy.MaxMessages))
+			}
+			total += n
+		}
+	}
+	return total, nil
+}
+
+func pruneChat(where string, args ...interface{}) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	n, err := deleteMessagesWhere(tx, where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// StartRetentionPruner applies retention policies periodically until ctx is done.
+func StartRetentionPruner(ctx context.Context) {
+	interval := time.Duration(currentConfig().Retention.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := PruneMessages(); err != nil {
+			log.Printf("Retention pruner: %v", err)
+		} else if n > 0 {
+			log.Printf("Retention pruner removed %d messages", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
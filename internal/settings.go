@@ -0,0 +1,304 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings scopes, from most to least specific.
+const (
+	ScopeUser   = "user"
+	ScopeChat   = "chat"
+	ScopeBot    = "bot"
+	ScopeGlobal = "global"
+	ScopeNone   = "default"
+)
+
+// SettingFields lists the AI settings that can be overridden per chat or user,
+// mapped to their column in chat_settings and user_settings.
+var SettingFields = map[string]string{
+	"model":       "model",
+	"persona":     "system_prompt",
+	"temperature": "temperature",
+	"length":      "max_reply_chars",
+	"language":    "language",
+}
+
+var settingFieldAliases = map[string]string{
+	"prompt":    "persona",
+	"system":    "persona",
+	"temp":      "temperature",
+	"max":       "length",
+	"maxlength": "length",
+	"lang":      "language",
+}
+
+var aiSettingColumns = [][2]string{
+	{"model", "TEXT"},
+	{"system_prompt", "TEXT"},
+	{"temperature", "REAL"},
+	{"max_reply_chars", "INTEGER"},
+	{"language", "TEXT"},
+}
+
+func initSettingsTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS user_settings (
+			user_id INTEGER PRIMARY KEY,
+			updated DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"chat_settings", "user_settings"} {
+		for _, col := range aiSettingColumns {
+			if err := ensureColumn(table, col[0], col[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AIOverrides are the AI settings stored for one chat or user. Empty strings,
+// a nil Temperature and a zero MaxReplyChars mean "not set".
+type AIOverrides struct {
+	Model         string
+	SystemPrompt  string
+	Temperature   *float64
+	MaxReplyChars int
+	Language      string
+}
+
+func settingsTable(scope string) (table, key string, err error) {
+	switch scope {
+	case ScopeChat:
+		return "chat_settings", "chat_id", nil
+	case ScopeUser:
+		return "user_settings", "user_id", nil
+	}
+	return "", "", fmt.Errorf("unknown settings scope %q", scope)
+}
+
+// GetAIOverrides returns the overrides stored for a chat or user.
+func GetAIOverrides(scope string, id int64) AIOverrides {
+	var o AIOverrides
+	table, key, err := settingsTable(scope)
+	if err != nil || DB == nil {
+		return o
+	}
+	var model, prompt, lang sql.NullString
+	var temp sql.NullFloat64
+	var maxChars sql.NullInt64
+	err = DB.QueryRow(fmt.Sprintf(`SELECT model, system_prompt, temperature, max_reply_chars, language FROM %s WHERE %s = ?`, table, key), id).
+		Scan(&model, &prompt, &temp, &maxChars, &lang)
+	if err != nil {
+		return o
+	}
+	o.Model, o.SystemPrompt, o.Language = model.String, prompt.String, lang.String
+	o.MaxReplyChars = int(maxChars.Int64)
+	if temp.Valid {
+		t := temp.Float64
+		o.Temperature = &t
+	}
+	return o
+}
+
+// NormalizeSettingField maps aliases such as "temp" or "prompt" onto a field
+// in SettingFields.
+func NormalizeSettingField(field string) (string, error) {
+	field = strings.ToLower(strings.TrimSpace(field))
+	if alias, ok := settingFieldAliases[field]; ok {
+		field = alias
+	}
+	if _, ok := SettingFields[field]; !ok {
+		return "", fmt.Errorf("unknown setting %q; use model, persona, temperature, length or language", field)
+	}
+	return field, nil
+}
+
+// SetAIOverride stores one setting for a chat or user. An empty value, "off"
+// or "default" clears the override so the next scope applies.
+func SetAIOverride(scope string, id int64, field, value string) error {
+	table, key, err := settingsTable(scope)
+	if err != nil {
+		return err
+	}
+	field, err = NormalizeSettingField(field)
+	if err != nil {
+		return err
+	}
+	value = strings.TrimSpace(value)
+	var v interface{}
+	switch strings.ToLower(value) {
+	case "", "off", "default", "none", "-":
+	default:
+		switch field {
+		case "temperature":
+			t, err := strconv.ParseFloat(value, 64)
+			if err != nil || t < 0 || t > 2 {
+				return fmt.Errorf("temperature must be a number between 0 and 2")
+			}
+			v = t
+		case "length":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 50 {
+				return fmt.Errorf("length must be a number of characters, at least 50")
+			}
+			v = n
+		default:
+			v = value
+		}
+	}
+	column := SettingFields[field]
+	_, err = DB.Exec(fmt.Sprintf(`INSERT INTO %[1]s (%[2]s, %[3]s, updated) VALUES (?, ?, ?)
+		ON CONFLICT(%[2]s) DO UPDATE SET %[3]s = excluded.%[3]s, updated = excluded.updated`, table, key, column),
+		id, v, time.Now().UTC())
+	return err
+}
+
+// ResetAIOverrides clears every AI override for a chat or user.
+func ResetAIOverrides(scope string, id int64) error {
+	table, key, err := settingsTable(scope)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(fmt.Sprintf(`UPDATE %s SET model = NULL, system_prompt = NULL, temperature = NULL,
+		max_reply_chars = NULL, language = NULL, updated = ? WHERE %s = ?`, table, key), time.Now().UTC(), id)
+	return err
+}
+
+// AISettings are the effective AI settings for a message. Sources records
+// which scope each field came from (user, chat, bot, global or default).
+type AISettings struct {
+	Model         string
+	SystemPrompt  string
+	Temperature   *float64
+	MaxReplyChars int
+	Language      string
+	Sources       map[string]string
+}
+
+// ResolveAISettings applies, in order of precedence: the user's settings,
+// the chat's settings, the running bot's config, the global config and the
+// built-in defaults.
+func ResolveAISettings(chatID, userID int64) AISettings {
+	cfg := currentConfig()
+	s := AISettings{Model: defaultAIModel, Sources: map[string]string{}}
+	for field := range SettingFields {
+		s.Sources[field] = ScopeNone
+	}
+	apply := func(scope string, o AIOverrides) {
+		if o.Model != "" {
+			s.Model, s.Sources["model"] = o.Model, scope
+		}
+		if o.SystemPrompt != "" {
+			s.SystemPrompt, s.Sources["persona"] = o.SystemPrompt, scope
+		}
+		if o.Temperature != nil {
+			s.Temperature, s.Sources["temperature"] = o.Temperature, scope
+		}
+		if o.MaxReplyChars > 0 {
+			s.MaxReplyChars, s.Sources["length"] = o.MaxReplyChars, scope
+		}
+		if o.Language != "" {
+			s.Language, s.Sources["language"] = o.Language, scope
+		}
+	}
+	// Lowest precedence first so more specific scopes overwrite.
+	apply(ScopeGlobal, AIOverrides{Model: cfg.DefaultAIModel, SystemPrompt: cfg.DefaultAIPrompt})
+	if b, ok := cfg.Bots[cfg.DefaultBotID]; ok {
+		apply(ScopeBot, AIOverrides{Model: b.AIModel, SystemPrompt: b.AIPrompt})
+	}
+	if chatID != 0 {
+		apply(ScopeChat, GetAIOverrides(ScopeChat, chatID))
+	}
+	if userID != 0 {
+		apply(ScopeUser, GetAIOverrides(ScopeUser, userID))
+	}
+	return s
+}
+
+// System returns the system prompt including the language instruction.
+func (s AISettings) System() string {
+	system := s.SystemPrompt
+	if s.Language != "" {
+		system = strings.TrimSpace(system + "\nAlways reply in " + s.Language + ".")
+	}
+	if s.MaxReplyChars > 0 {
+		system = strings.TrimSpace(fmt.Sprintf("%s\nKeep replies under %d characters.", system, s.MaxReplyChars))
+	}
+	return system
+}
+
+// Request builds an Ollama request for prompt with these settings applied.
+func (s AISettings) Request(prompt string) OllamaRequest {
+	r := OllamaRequest{Model: s.Model, Prompt: prompt, System: s.System()}
+	if s.Temperature != nil {
+		r.Options = map[string]interface{}{"temperature": *s.Temperature}
+	}
+	return r
+}
+
+// Truncate shortens reply to MaxReplyChars, cutting at a word boundary.
+func (s AISettings) Truncate(reply string) string {
+	if s.MaxReplyChars <= 0 {
+		return reply
+	}
+	runes := []rune(reply)
+	if len(runes) <= s.MaxReplyChars {
+		return reply
+	}
+	cut := string(runes[:s.MaxReplyChars])
+	if i := strings.LastIndexAny(cut, " \n"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
+
+// settingsAIChat answers prompt with settings s (see ResolveAISettings). ctx
+// carries the caller's AITask, under which the request is queued and recorded.
+// A non-nil key lets the answer come from, and go into, the response cache.
+func settingsAIChat(ctx context.Context, s AISettings, prompt string, key *CacheKey) (string, error) {
+	var resp *OllamaResponse
+	var err error
+	if key != nil {
+		resp, _, err = CachedGenerate(ctx, s.Request(prompt), *key)
+	} else {
+		resp, err = GenerateWithFallback(ctx, s.Request(prompt))
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.Truncate(strings.TrimSpace(resp.Response)), nil
+}
+
+// FormatAISettings describes the effective settings and where each comes from.
+func FormatAISettings(s AISettings) string {
+	temp := "model default"
+	if s.Temperature != nil {
+		temp = strconv.FormatFloat(*s.Temperature, 'f', -1, 64)
+	}
+	length := "unlimited"
+	if s.MaxReplyChars > 0 {
+		length = fmt.Sprintf("%d chars", s.MaxReplyChars)
+	}
+	lang := s.Language
+	if lang == "" {
+		lang = "auto"
+	}
+	persona := s.SystemPrompt
+	if persona == "" {
+		persona = "(none)"
+	} else if r := []rune(persona); len(r) > 80 {
+		persona = string(r[:80]) + "…"
+	}
+	return fmt.Sprintf("Model: %s [%s]\nPersona: %s [%s]\nTemperature: %s [%s]\nMax reply: %s [%s]\nLanguage: %s [%s]",
+		s.Model, s.Sources["model"], persona, s.Sources["persona"], temp, s.Sources["temperature"],
+		length, s.Sources["length"], lang, s.Sources["language"])
+}
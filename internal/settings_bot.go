@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// settingsCallbackPrefix marks /settings keyboard callbacks:
+// "set:<c|u>:<field>" opens a field's options, "set:<c|u>:<field>:<value>"
+// applies one ("-" clears it) and "set:<c|u>:reset" clears every override.
+const settingsCallbackPrefix = "set:"
+
+const settingsUsage = `Usage:
+/settings — this chat's AI settings (chat admins can change them)
+/settings me — your personal AI settings, which override the chat's
+/settings [me] <model|persona|temperature|length|language> <value|off>
+/settings [me] reset`
+
+// settingsOptions are the choices offered on the inline keyboard; "-" resets
+// the field to the next scope's value. Models are listed from Ollama.
+var settingsOptions = map[string][]string{
+	"temperature": {"0", "0.3", "0.7", "1", "-"},
+	"length":      {"300", "800", "1500", "3000", "-"},
+	"language":    {"English", "Spanish", "French", "German", "Portuguese", "-"},
+}
+
+// handleSettingsCommand shows or changes the AI settings for the chat or,
+// with "me", for the sender.
+func handleSettingsCommand(bot *tgbotapi.BotAPI, m *tgbotapi.Message, args string) {
+	scope := ScopeChat
+	first, rest := splitFirstWord(args)
+	if strings.EqualFold(first, "me") {
+		scope = ScopeUser
+		first, rest = splitFirstWord(rest)
+	}
+	reply := func(text string) { bot.Send(tgbotapi.NewMessage(m.Chat.ID, text)) }
+	if first == "" {
+		msg := tgbotapi.NewMessage(m.Chat.ID, settingsText(scope, m.Chat.ID, m.From.ID))
+		msg.ReplyMarkup = settingsKeyboard(scope)
+		bot.Send(msg)
+		return
+	}
+	if first == "help" {
+		reply(settingsUsage)
+		return
+	}
+	if scope == ScopeChat && !canManageChat(bot, m.Chat, m.From.ID) {
+		reply("⛔ Only chat admins can change this chat's settings. Use /settings me to change your own.")
+		return
+	}
+	id := settingsScopeID(scope, m.Chat.ID, m.From.ID)
+	var err error
+	if strings.EqualFold(first, "reset") {
+		err = ResetAIOverrides(scope, id)
+	} else {
+		err = SetAIOverride(scope, id, first, rest)
+	}
+	if err != nil {
+		reply("[Settings error] " + err.Error() + "\n\n" + settingsUsage)
+		return
+	}
+	log.Printf("[SETTINGS] %s %d: %s by %d", scope, id, strings.TrimSpace(args), m.From.ID)
+	reply("✅ Updated\n" + settingsText(scope, m.Chat.ID, m.From.ID))
+}
+
+// handleSettingsCallback handles presses on the /settings keyboard.
+func handleSettingsCallback(bot *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
+	parts := strings.SplitN(strings.TrimPrefix(cq.Data, settingsCallbackPrefix), ":", 3)
+	if len(parts) < 2 || cq.Message == nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "Invalid settings button"))
+		return
+	}
+	scope := ScopeChat
+	if parts[0] == "u" {
+		scope = ScopeUser
+	}
+	chat := cq.Message.Chat
+	field := parts[1]
+	edit := func(text string, markup tgbotapi.InlineKeyboardMarkup) {
+		bot.Request(tgbotapi.NewEditMessageTextAndMarkup(chat.ID, cq.Message.MessageID, text, markup))
+	}
+
+	if field == "back" {
+		bot.Request(tgbotapi.NewCallback(cq.ID, ""))
+		edit(settingsText(scope, chat.ID, cq.From.ID), settingsKeyboard(scope))
+		return
+	}
+	if len(parts) == 2 && field != "reset" {
+		options, err := settingsFieldOptions(field)
+		if err != nil {
+			bot.Request(tgbotapi.NewCallback(cq.ID, err.Error()))
+			return
+		}
+		bot.Request(tgbotapi.NewCallback(cq.ID, ""))
+		edit(fmt.Sprintf("Choose %s:", field), settingsOptionsKeyboard(parts[0], field, options))
+		return
+	}
+
+	if scope == ScopeChat && !canManageChat(bot, chat, cq.From.ID) {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "⛔ Only chat admins can change this chat's settings"))
+		return
+	}
+	id := settingsScopeID(scope, chat.ID, cq.From.ID)
+	var err error
+	if field == "reset" {
+		err = ResetAIOverrides(scope, id)
+	} else {
+		err = SetAIOverride(scope, id, field, parts[2])
+	}
+	if err != nil {
+		bot.Request(tgbotapi.NewCallback(cq.ID, "[Settings error] "+err.Error()))
+		return
+	}
+	log.Printf("[SETTINGS] %s %d: %s by %d", scope, id, strings.Join(parts[1:], " "), cq.From.ID)
+	bot.Request(tgbotapi.NewCallback(cq.ID, "✅ Saved"))
+	edit(settingsText(scope, chat.ID, cq.From.ID), settingsKeyboard(scope))
+}
+
+// settingsScopeID returns the chat or user ID that a scope's settings belong to.
+func settingsScopeID(scope string, chatID, userID int64) int64 {
+	if scope == ScopeUser {
+		return userID
+	}
+	return chatID
+}
+
+// canManageChat reports whether userID may change chat-wide settings: anyone
+// in a private chat, otherwise chat admins only.
+func canManageChat(bot *tgbotapi.BotAPI, chat *tgbotapi.Chat, userID int64) bool {
+	return chat.IsPrivate() || isChatAdmin(bot, chat.ID, userID)
+}
+
+func settingsText(scope string, chatID, userID int64) string {
+	if scope == ScopeUser {
+		return "⚙️ Your AI settings in this chat\n\n" + FormatAISettings(ResolveAISettings(chatID, userID))
+	}
+	return "⚙️ AI settings for this chat\n\n" + FormatAISettings(ResolveAISettings(chatID, 0))
+}
+
+func settingsKeyboard(scope string) tgbotapi.InlineKeyboardMarkup {
+	s := "c"
+	if scope == ScopeUser {
+		s = "u"
+	}
+	button := func(text, data string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(text, settingsCallbackPrefix+s+":"+data)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(button("🤖 Model", "model"), button("🌡 Temperature", "temperature")),
+		tgbotapi.NewInlineKeyboardRow(button("📏 Length", "length"), button("🌐 Language", "language")),
+		tgbotapi.NewInlineKeyboardRow(button("♻️ Reset", "reset")),
+	)
+}
+
+// settingsFieldOptions returns the keyboard choices for field.
+func settingsFieldOptions(field string) ([]string, error) {
+	if field != "model" {
+		options, ok := settingsOptions[field]
+		if !ok {
+			return nil, fmt.Errorf("set %s with /settings [me] %s <value>", field, field)
+		}
+		return options, nil
+	}
+	models, err := ListOllamaModels()
+	if err != nil {
+		return nil, fmt.Errorf("could not list models: %v", err)
+	}
+	var options []string
+	for _, m := range models {
+		options = append(options, m.Name)
+	}
+	return append(options, "-"), nil
+}
+
+func settingsOptionsKeyboard(s, field string, options []string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, opt := range options {
+		data := fmt.Sprintf("%s%s:%s:%s", settingsCallbackPrefix, s, field, opt)
+		if len(data) > 64 { // Telegram's callback data limit
+			continue
+		}
+		text := opt
+		if opt == "-" {
+			text = "Default"
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(text, data))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("« Back", settingsCallbackPrefix+s+":back")))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -0,0 +1,280 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ChatStats aggregates activity for one chat (or all chats when ChatID is 0)
+// over a period.
+type ChatStats struct {
+	ChatID       int64        `json:"chat_id"`
+	Period       string       `json:"period"`
+	Since        time.Time    `json:"since,omitempty"`
+	Messages     int          `json:"messages"`
+	ActiveUsers  int          `json:"active_users"`
+	TopUsers     []UserCount  `json:"top_users"`
+	Types        []LabelCount `json:"types"`
+	AIRequests   int          `json:"ai_requests"`
+	AIFailures   int          `json:"ai_failures"`
+	AIByKind     []LabelCount `json:"ai_by_kind"`
+	AvgLatencyMs int64        `json:"avg_latency_ms"`
+	P95LatencyMs int64        `json:"p95_latency_ms"`
+	MaxLatencyMs int64        `json:"max_latency_ms"`
+}
+
+type UserCount struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Count    int    `json:"count"`
+}
+
+type LabelCount struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+func initStatsTables() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS ai_requests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER,
+			user_id INTEGER,
+			kind TEXT,
+			model TEXT,
+			duration_ms INTEGER,
+			success BOOLEAN,
+			error TEXT,
+			created DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	// Token counts and Ollama's own timings, added for usage reports and quotas.
+	for _, col := range []string{"prompt_tokens", "eval_tokens", "load_ms", "prompt_eval_ms", "eval_ms"} {
+		if err := ensureColumn("ai_requests", col, "INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_ai_requests_chat_created ON ai_requests (chat_id, created)`)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_ai_requests_user_created ON ai_requests (user_id, created)`)
+	return err
+}
+
+// RecordAIRequest stores the outcome, latency and token usage of one AI call.
+// The latency runs from started, which excludes time spent in the AI queue.
+func RecordAIRequest(chatID, userID int64, kind, model string, started time.Time, usage AIUsage, callErr error) {
+	errText := ""
+	if callErr != nil {
+		errText = callErr.Error()
+	}
+	_, err := DB.Exec(`INSERT INTO ai_requests (chat_id, user_id, kind, model, duration_ms, success, error, created,
+		prompt_tokens, eval_tokens, load_ms, prompt_eval_ms, eval_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		chatID, userID, kind, model, time.Since(started).Milliseconds(), callErr == nil, errText, started.UTC(),
+		usage.PromptTokens, usage.EvalTokens, usage.Load.Milliseconds(), usage.PromptEval.Milliseconds(), usage.Eval.Milliseconds())
+	if err != nil {
+		log.Printf("Error recording AI request: %v", err)
+	}
+}
+
+// ParseStatsPeriod turns "today", "24h", "7d", "30d" or "all" into the start
+// of the period. An empty period defaults to the last 7 days.
+func ParseStatsPeriod(period string) (time.Time, string, error) {
+	period = strings.ToLower(strings.TrimSpace(period))
+	switch period {
+	case "":
+		period = "7d"
+	case "all":
+		return time.Time{}, "all time", nil
+	case "today":
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), "today", nil
+	}
+	d, err := ParseDurationArg(period)
+	if err != nil || d <= 0 {
+		return time.Time{}, "", fmt.Errorf("invalid period %q: use today, 24h, 7d, 30d or all", period)
+	}
+	return time.Now().Add(-d), "last " + period, nil
+}
+
+// ComputeChatStats aggregates message and AI activity since the given time.
+// chatID 0 covers all chats.
+func ComputeChatStats(chatID int64, since time.Time, period string) (*ChatStats, error) {
+	s := &ChatStats{ChatID: chatID, Period: period, Since: since}
+	where := "created >= ?"
+	args := []interface{}{since.UTC()}
+	if chatID != 0 {
+		where += " AND chat_id = ?"
+		args = append(args, chatID)
+	}
+
+	if err := DB.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT user_id) FROM messages WHERE is_bot = 0 AND `+where, args...).
+		Scan(&s.Messages, &s.ActiveUsers); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT user_id, MAX(COALESCE(username, '')), COUNT(*) AS n FROM messages
+		WHERE is_bot = 0 AND `+where+` GROUP BY user_id ORDER BY n DESC LIMIT 5`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var u UserCount
+		if err := rows.Scan(&u.UserID, &u.Username, &u.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.TopUsers = append(s.TopUsers, u)
+	}
+	rows.Close()
+
+	if s.Types, err = labelCounts(`SELECT COALESCE(type, 'message'), COUNT(*) AS n FROM messages
+		WHERE is_bot = 0 AND `+where+` GROUP BY 1 ORDER BY n DESC`, args...); err != nil {
+		return nil, err
+	}
+	if s.AIByKind, err = labelCounts(`SELECT COALESCE(kind, ''), COUNT(*) AS n FROM ai_requests
+		WHERE `+where+` GROUP BY 1 ORDER BY n DESC`, args...); err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query(`SELECT duration_ms, success FROM ai_requests WHERE `+where+` ORDER BY duration_ms`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var latencies []int64 // ascending, from the ORDER BY
+	for rows.Next() {
+		var ms int64
+		var ok bool
+		if err := rows.Scan(&ms, &ok); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.AIRequests++
+		if !ok {
+			s.AIFailures++
+			continue
+		}
+		latencies = append(latencies, ms)
+	}
+	rows.Close()
+	if len(latencies) > 0 {
+		var sum int64
+		for _, ms := range latencies {
+			sum += ms
+		}
+		s.AvgLatencyMs = sum / int64(len(latencies))
+		s.P95LatencyMs = latencies[(len(latencies)*95+99)/100-1]
+		s.MaxLatencyMs = latencies[len(latencies)-1]
+	}
+	return s, nil
+}
+
+func labelCounts(query string, args ...interface{}) ([]LabelCount, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []LabelCount
+	for rows.Next() {
+		var lc LabelCount
+		if err := rows.Scan(&lc.Label, &lc.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, lc)
+	}
+	return out, rows.Err()
+}
+
+// FormatChatStats renders stats as a plain-text chat reply.
+func FormatChatStats(s *ChatStats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Stats for %s\n\n", s.Period)
+	fmt.Fprintf(&b, "Messages: %d from %d users\n", s.Messages, s.ActiveUsers)
+	if len(s.TopUsers) > 0 {
+		b.WriteString("\nMost active:\n")
+		for i, u := range s.TopUsers {
+			fmt.Fprintf(&b, "%d. %s — %d\n", i+1, displayName(u), u.Count)
+		}
+	}
+	if len(s.Types) > 0 {
+		b.WriteString("\nMessage types:\n")
+		for _, t := range s.Types {
+			fmt.Fprintf(&b, "• %s: %d (%.0f%%)\n", t.Label, t.Count, percent(t.Count, s.Messages))
+		}
+	}
+	fmt.Fprintf(&b, "\nAI requests: %d", s.AIRequests)
+	if s.AIFailures > 0 {
+		fmt.Fprintf(&b, " (%d failed)", s.AIFailures)
+	}
+	b.WriteString("\n")
+	for _, k := range s.AIByKind {
+		fmt.Fprintf(&b, "• %s: %d\n", k.Label, k.Count)
+	}
+	if s.AIRequests > s.AIFailures {
+		fmt.Fprintf(&b, "Latency: avg %s, p95 %s, max %s\n",
+			formatMs(s.AvgLatencyMs), formatMs(s.P95LatencyMs), formatMs(s.MaxLatencyMs))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// WriteChatStatsTable renders stats as aligned tables for the CLI.
+func WriteChatStatsTable(w io.Writer, s *ChatStats) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	scope := "all chats"
+	if s.ChatID != 0 {
+		scope = fmt.Sprintf("chat %d", s.ChatID)
+	}
+	fmt.Fprintf(tw, "Scope\t%s\n", scope)
+	fmt.Fprintf(tw, "Period\t%s\n", s.Period)
+	fmt.Fprintf(tw, "Messages\t%d\n", s.Messages)
+	fmt.Fprintf(tw, "Active users\t%d\n", s.ActiveUsers)
+	fmt.Fprintf(tw, "AI requests\t%d\n", s.AIRequests)
+	fmt.Fprintf(tw, "AI failures\t%d\n", s.AIFailures)
+	fmt.Fprintf(tw, "Latency avg/p95/max\t%s / %s / %s\n", formatMs(s.AvgLatencyMs), formatMs(s.P95LatencyMs), formatMs(s.MaxLatencyMs))
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "USER\tID\tMESSAGES")
+	for _, u := range s.TopUsers {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", displayName(u), u.UserID, u.Count)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "TYPE\tMESSAGES\tSHARE")
+	for _, t := range s.Types {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f%%\n", t.Label, t.Count, percent(t.Count, s.Messages))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "AI KIND\tREQUESTS")
+	for _, k := range s.AIByKind {
+		fmt.Fprintf(tw, "%s\t%d\n", k.Label, k.Count)
+	}
+	return tw.Flush()
+}
+
+func displayName(u UserCount) string {
+	if u.Username == "" {
+		return fmt.Sprintf("user %d", u.UserID)
+	}
+	return u.Username
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+func formatMs(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(10 * time.Millisecond).String()
+}
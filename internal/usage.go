@@ -0,0 +1,339 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// AIUsage is the token and timing information Ollama returns for one call.
+type AIUsage struct {
+	PromptTokens int
+	EvalTokens   int
+	Load         time.Duration
+	PromptEval   time.Duration
+	Eval         time.Duration
+}
+
+func usageOf(resp *OllamaResponse) AIUsage {
+	if resp == nil {
+		return AIUsage{}
+	}
+	return AIUsage{
+		PromptTokens: resp.PromptEvalCount,
+		EvalTokens:   resp.EvalCount,
+		Load:         time.Duration(resp.LoadDuration),
+		PromptEval:   time.Duration(resp.PromptEvalDuration),
+		Eval:         time.Duration(resp.EvalDuration),
+	}
+}
+
+// ErrAIQuota is wrapped by the *QuotaError returned when a chat or user has
+// used up its daily token quota.
+var ErrAIQuota = errors.New("daily AI quota used up")
+
+// QuotaError describes which daily quota was hit.
+type QuotaError struct {
+	Scope  string // ScopeChat or ScopeUser
+	Used   int64
+	Limit  int64
+	Resets time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s daily AI quota used up (%d/%d tokens)", e.Scope, e.Used, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error { return ErrAIQuota }
+
+// Message is the chat reply for a request refused by the quota.
+func (e *QuotaError) Message() string {
+	who := "This chat has"
+	if e.Scope == ScopeUser {
+		who = "You have"
+	}
+	wait := time.Until(e.Resets).Round(time.Minute)
+	return fmt.Sprintf("🪫 %s used today's AI budget (%s of %s tokens). It resets in %s; until then I'll stick to canned replies.",
+		who, formatTokens(e.Used), formatTokens(e.Limit), formatWait(wait))
+}
+
+// startOfDay returns local midnight of t's day; quotas reset then.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// tokensSince sums the tokens used by a chat or user (column chat_id or
+// user_id) since the given time.
+func tokensSince(column string, id int64, since time.Time) (int64, error) {
+	var n int64
+	err := DB.QueryRow(`SELECT COALESCE(SUM(prompt_tokens + eval_tokens), 0) FROM ai_requests WHERE `+column+` = ? AND created >= ?`,
+		id, since.UTC()).Scan(&n)
+	return n, err
+}
+
+// checkAIQuota returns a *QuotaError if the task's chat or user has reached
+// its configured daily token quota. Bot admins are never limited.
+func checkAIQuota(t AITask) error {
+	cfg := currentConfig()
+	quota := cfg.AIQuota
+	if t.UserID != 0 && cfg.IsAdmin(t.UserID) {
+		return nil
+	}
+	now := time.Now()
+	since := startOfDay(now)
+	resets := since.AddDate(0, 0, 1)
+	checks := []struct {
+		scope, column string
+		id            int64
+		limit         int
+	}{
+		{ScopeChat, "chat_id", t.ChatID, quota.ChatDailyTokens},
+		{ScopeUser, "user_id", t.UserID, quota.UserDailyTokens},
+	}
+	for _, c := range checks {
+		if c.id == 0 || c.limit <= 0 {
+			continue
+		}
+		used, err := tokensSince(c.column, c.id, since)
+		if err != nil {
+			return err
+		}
+		if used >= int64(c.limit) {
+			return &QuotaError{Scope: c.scope, Used: used, Limit: int64(c.limit), Resets: resets}
+		}
+	}
+	return nil
+}
+
+// AIUsageRow aggregates AI calls sharing a model, kind, chat or user.
+type AIUsageRow struct {
+	Key          string `json:"key"`
+	Name         string `json:"name,omitempty"`
+	Requests     int    `json:"requests"`
+	Failures     int    `json:"failures"`
+	PromptTokens int64  `json:"prompt_tokens"`
+	EvalTokens   int64  `json:"eval_tokens"`
+	EvalMs       int64  `json:"eval_ms"`
+}
+
+// Tokens is the total of prompt and generated tokens.
+func (r AIUsageRow) Tokens() int64 { return r.PromptTokens + r.EvalTokens }
+
+// TokensPerSecond is the generation speed, or 0 if unknown.
+func (r AIUsageRow) TokensPerSecond() float64 {
+	if r.EvalMs == 0 {
+		return 0
+	}
+	return float64(r.EvalTokens) * 1000 / float64(r.EvalMs)
+}
+
+// AIUsageReport breaks down AI usage for one chat (or all chats when ChatID
+// is 0) over a period.
+type AIUsageReport struct {
+	ChatID  int64        `json:"chat_id"`
+	Period  string       `json:"period"`
+	Since   time.Time    `json:"since,omitempty"`
+	Total   AIUsageRow   `json:"total"`
+	ByModel []AIUsageRow `json:"by_model"`
+	ByKind  []AIUsageRow `json:"by_kind"`
+	ByChat  []AIUsageRow `json:"by_chat,omitempty"`
+	ByUser  []AIUsageRow `json:"by_user"`
+}
+
+// ComputeAIUsage aggregates recorded AI calls since the given time. chatID 0
+// covers all chats and adds a per-chat breakdown.
+func ComputeAIUsage(chatID int64, since time.Time, period string) (*AIUsageReport, error) {
+	r := &AIUsageReport{ChatID: chatID, Period: period, Since: since}
+	where := "created >= ?"
+	args := []interface{}{since.UTC()}
+	if chatID != 0 {
+		where += " AND chat_id = ?"
+		args = append(args, chatID)
+	}
+	totals, err := usageRows(`''`, where, "", 1, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(totals) > 0 {
+		r.Total = totals[0]
+		r.Total.Key = ""
+	}
+	if r.ByModel, err = usageRows(`COALESCE(model, '')`, where, "", 0, args); err != nil {
+		return nil, err
+	}
+	if r.ByKind, err = usageRows(`COALESCE(kind, '')`, where, "", 0, args); err != nil {
+		return nil, err
+	}
+	if chatID == 0 {
+		if r.ByChat, err = usageRows(`chat_id`, where, "", 10, args); err != nil {
+			return nil, err
+		}
+	}
+	userName := `(SELECT COALESCE(username, '') FROM messages m WHERE m.user_id = ai_requests.user_id AND COALESCE(username, '') != '' ORDER BY m.id DESC LIMIT 1)`
+	if r.ByUser, err = usageRows(`user_id`, where+" AND user_id != 0", userName, 10, args); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// usageRows groups ai_requests matching where by key, busiest first. name is
+// an optional SQL expression for a display name.
+func usageRows(key, where, name string, limit int, args []interface{}) ([]AIUsageRow, error) {
+	if name == "" {
+		name = `''`
+	}
+	query := `SELECT CAST(` + key + ` AS TEXT), COALESCE(MAX(` + name + `), ''), COUNT(*), SUM(CASE WHEN success THEN 0 ELSE 1 END),
+		COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(eval_tokens), 0), COALESCE(SUM(eval_ms), 0)
+		FROM ai_requests WHERE ` + where + ` GROUP BY 1
+		ORDER BY COALESCE(SUM(prompt_tokens + eval_tokens), 0) DESC, COUNT(*) DESC`
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AIUsageRow
+	for rows.Next() {
+		var u AIUsageRow
+		if err := rows.Scan(&u.Key, &u.Name, &u.Requests, &u.Failures, &u.PromptTokens, &u.EvalTokens, &u.EvalMs); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// FormatAIQuotaUsage describes today's usage against the configured quotas
+// for a chat and user, or "" when no quota is set.
+func FormatAIQuotaUsage(chatID, userID int64) string {
+	quota := currentConfig().AIQuota
+	since := startOfDay(time.Now())
+	var lines []string
+	if quota.ChatDailyTokens > 0 {
+		used, _ := tokensSince("chat_id", chatID, since)
+		lines = append(lines, fmt.Sprintf("• this chat: %s / %s tokens", formatTokens(used), formatTokens(int64(quota.ChatDailyTokens))))
+	}
+	if quota.UserDailyTokens > 0 && userID != 0 {
+		used, _ := tokensSince("user_id", userID, since)
+		lines = append(lines, fmt.Sprintf("• you: %s / %s tokens", formatTokens(used), formatTokens(int64(quota.UserDailyTokens))))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "Daily quota (resets at midnight):\n" + strings.Join(lines, "\n")
+}
+
+// FormatAIUsage renders a report as a plain-text chat reply.
+func FormatAIUsage(r *AIUsageReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧠 AI usage for %s\n\n", r.Period)
+	fmt.Fprintf(&b, "Requests: %d", r.Total.Requests)
+	if r.Total.Failures > 0 {
+		fmt.Fprintf(&b, " (%d failed)", r.Total.Failures)
+	}
+	fmt.Fprintf(&b, "\nTokens: %s (%s prompt, %s generated)\n",
+		formatTokens(r.Total.Tokens()), formatTokens(r.Total.PromptTokens), formatTokens(r.Total.EvalTokens))
+	if len(r.ByModel) > 0 {
+		b.WriteString("\nBy model:\n")
+		for _, m := range r.ByModel {
+			fmt.Fprintf(&b, "• %s: %d requests, %s tokens", m.Key, m.Requests, formatTokens(m.Tokens()))
+			if tps := m.TokensPerSecond(); tps > 0 {
+				fmt.Fprintf(&b, ", %.1f tok/s", tps)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(r.ByKind) > 0 {
+		b.WriteString("\nBy feature:\n")
+		for _, k := range r.ByKind {
+			fmt.Fprintf(&b, "• %s: %d requests, %s tokens\n", k.Key, k.Requests, formatTokens(k.Tokens()))
+		}
+	}
+	if len(r.ByUser) > 0 {
+		b.WriteString("\nTop users:\n")
+		for i, u := range r.ByUser {
+			fmt.Fprintf(&b, "%d. %s — %s tokens\n", i+1, usageRowName(u, "user"), formatTokens(u.Tokens()))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// WriteAIUsageTable renders a report as aligned tables for the CLI.
+func WriteAIUsageTable(w io.Writer, r *AIUsageReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	scope := "all chats"
+	if r.ChatID != 0 {
+		scope = fmt.Sprintf("chat %d", r.ChatID)
+	}
+	fmt.Fprintf(tw, "Scope\t%s\n", scope)
+	fmt.Fprintf(tw, "Period\t%s\n", r.Period)
+	fmt.Fprintf(tw, "Requests\t%d\n", r.Total.Requests)
+	fmt.Fprintf(tw, "Failures\t%d\n", r.Total.Failures)
+	fmt.Fprintf(tw, "Prompt tokens\t%d\n", r.Total.PromptTokens)
+	fmt.Fprintf(tw, "Generated tokens\t%d\n", r.Total.EvalTokens)
+	sections := []struct {
+		title, kind string
+		rows        []AIUsageRow
+	}{
+		{"MODEL", "", r.ByModel},
+		{"KIND", "", r.ByKind},
+		{"CHAT", "chat", r.ByChat},
+		{"USER", "user", r.ByUser},
+	}
+	for _, s := range sections {
+		if len(s.rows) == 0 {
+			continue
+		}
+		fmt.Fprintln(tw)
+		fmt.Fprintf(tw, "%s\tREQUESTS\tFAILED\tPROMPT\tGENERATED\tTOK/S\n", s.title)
+		for _, u := range s.rows {
+			name := u.Key
+			if s.kind != "" {
+				name = usageRowName(u, s.kind)
+			}
+			tps := "-"
+			if v := u.TokensPerSecond(); v > 0 {
+				tps = fmt.Sprintf("%.1f", v)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", name, u.Requests, u.Failures, u.PromptTokens, u.EvalTokens, tps)
+		}
+	}
+	return tw.Flush()
+}
+
+func usageRowName(u AIUsageRow, kind string) string {
+	if u.Name != "" {
+		return fmt.Sprintf("%s (%s)", u.Name, u.Key)
+	}
+	return kind + " " + u.Key
+}
+
+// formatTokens renders a token count compactly, e.g. 950, 12.3k or 1.2M.
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 10_000:
+		return fmt.Sprintf("%.0fk", float64(n)/1e3)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func formatWait(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	if h == 0 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh %dm", h, m)
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxImageBytes caps the size of images downloaded for the vision model.
+const maxImageBytes = 10 << 20
+
+// messageImage returns the image attached to m and its media reference for
+// the message record: the largest photo size within maxImageBytes, or an
+// image sent as a file.
+func messageImage(m *tgbotapi.Message) (fileID, media string, ok bool) {
+	if len(m.Photo) > 0 {
+		// Telegram lists photo sizes from smallest to largest.
+		for i := len(m.Photo) - 1; i >= 0; i-- {
+			if p := m.Photo[i]; p.FileSize <= maxImageBytes || i == 0 {
+				return p.FileID, "photo:" + p.FileID, true
+			}
+		}
+	}
+	if d := m.Document; d != nil && strings.HasPrefix(d.MimeType, "image/") {
+		return d.FileID, "image:" + d.FileID, true
+	}
+	return "", "", false
+}
+
+// downloadTelegramFile fetches a file sent to the bot, refusing files larger
+// than limit bytes.
+func downloadTelegramFile(ctx context.Context, bot *tgbotapi.BotAPI, fileID string, limit int64) ([]byte, error) {
+	url, err := bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file is larger than %s", FormatBytes(limit))
+	}
+	return data, nil
+}
+
+// handleImageMessage downloads the image in m and replies with the vision
+// model's analysis. It runs in its own goroutine and may wait in the AI queue.
+func handleImageMessage(ctx context.Context, bot *tgbotapi.BotAPI, m *tgbotapi.Message, msgType string) {
+	fileID, _, ok := messageImage(m)
+	if !ok {
+		return
+	}
+	reply := func(text string) {
+		msg := tgbotapi.NewMessage(m.Chat.ID, text)
+		msg.ReplyToMessageID = m.MessageID
+		bot.Send(msg)
+	}
+	bot.Send(tgbotapi.NewChatAction(m.Chat.ID, tgbotapi.ChatTyping))
+	dlCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	image, err := downloadTelegramFile(dlCtx, bot, fileID, maxImageBytes)
+	cancel()
+	if err != nil {
+		log.Printf("Error downloading image %s: %v", fileID, err)
+		reply("[AI error] Couldn't download the image: " + err.Error())
+		return
+	}
+
+	settings := ResolveAISettings(m.Chat.ID, m.From.ID)
+	req := settings.Request(renderMessagePrompt("vision", m, msgType, stripBotAddress(bot.Self, m.Caption), nil))
+	req.Model = currentConfig().AIVisionModel()
+	req.Images = []string{base64.StdEncoding.EncodeToString(image)}
+	task := aiTaskFor(m, "vision")
+	task.OnWait = queueNotifier(bot, m)
+	resp, err := GenerateWithFallback(WithAITask(ctx, task), req)
+	if text, ok := aiLimitText(err); ok {
+		if text != "" {
+			reply(text)
+		}
+		return
+	}
+	if errors.Is(err, ErrAIUnavailable) {
+		reply(DegradedMessage)
+		return
+	}
+	if err != nil {
+		reply("[AI error] " + err.Error())
+		return
+	}
+	reply(settings.Truncate(strings.TrimSpace(resp.Response)))
+}
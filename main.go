@@ -34,6 +34,13 @@ func main() {
 	rootCmd.AddCommand(cmd.AiCmd)
 	rootCmd.AddCommand(cmd.GitCmd)
 	rootCmd.AddCommand(cmd.GhCmd)
+	rootCmd.AddCommand(cmd.HistoryCmd)
+	rootCmd.AddCommand(cmd.IndexCmd)
+	rootCmd.AddCommand(cmd.StatsCmd)
+	rootCmd.AddCommand(cmd.AutoReplyCmd)
+	rootCmd.AddCommand(cmd.ClassifierCmd)
+	rootCmd.AddCommand(cmd.PromptCmd)
+	rootCmd.AddCommand(cmd.CacheCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)